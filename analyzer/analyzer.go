@@ -0,0 +1,397 @@
+// Package analyzer reports how a database uses disk space,
+// like the [sqlite3_analyzer] utility.
+//
+// The report is built from the [dbstat virtual table].
+//
+// [sqlite3_analyzer]: https://sqlite.org/sqlanalyze.html
+// [dbstat virtual table]: https://sqlite.org/dbstat.html
+package analyzer
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/ncruces/go-sqlite3"
+)
+
+// Options configure an [Analyze].
+type Options struct {
+	// Schema is the name of the schema to analyze.
+	// If empty, "main" is used.
+	Schema string
+}
+
+// Report is the result of an [Analyze].
+type Report struct {
+	Schema    string `json:"schema"`
+	PageSize  int64  `json:"page_size"`
+	PageCount int64  `json:"page_count"`
+	// FreePages is the number of pages on the freelist.
+	FreePages int64 `json:"free_pages"`
+	// Objects are the tables and indexes, largest first.
+	Objects []Object `json:"objects"`
+	// Total aggregates all objects.
+	Total Object `json:"total"`
+}
+
+// Object reports space usage for a table or index.
+// The report for a table doesn't include its indexes.
+type Object struct {
+	Name string `json:"name"`
+	// Table is the table that owns an index; for tables, it equals Name.
+	Table string `json:"table"`
+	Index bool   `json:"index"`
+	// Depth is the number of levels of the B-tree.
+	Depth int `json:"depth"`
+
+	InteriorPages int64 `json:"interior_pages"`
+	LeafPages     int64 `json:"leaf_pages"`
+	OverflowPages int64 `json:"overflow_pages"`
+
+	// Entries is the number of rows in a table, or of entries in an index.
+	Entries int64 `json:"entries"`
+	// OverflowEntries is the number of entries that use overflow pages.
+	OverflowEntries int64 `json:"overflow_entries"`
+	// Payload is the number of bytes of content, including keys.
+	Payload int64 `json:"payload"`
+	// MaxPayload is the size of the largest entry.
+	MaxPayload int64 `json:"max_payload"`
+	// Overhead is the number of bytes used by page headers,
+	// cell pointers and other metadata.
+	Overhead int64 `json:"overhead"`
+
+	InteriorUnused int64 `json:"interior_unused"`
+	LeafUnused     int64 `json:"leaf_unused"`
+	OverflowUnused int64 `json:"overflow_unused"`
+
+	// Gaps is the number of pages that don't immediately
+	// follow the previous page in B-tree order.
+	Gaps int64 `json:"gaps"`
+}
+
+// Pages returns the total number of pages.
+func (o *Object) Pages() int64 {
+	return o.InteriorPages + o.LeafPages + o.OverflowPages
+}
+
+// Unused returns the total number of unused bytes.
+func (o *Object) Unused() int64 {
+	return o.InteriorUnused + o.LeafUnused + o.OverflowUnused
+}
+
+// Fragmentation returns the percentage of pages
+// that are out of order.
+func (o *Object) Fragmentation() float64 {
+	if n := o.Pages(); n > 1 {
+		return 100 * float64(o.Gaps) / float64(n-1)
+	}
+	return 0
+}
+
+// Fanout returns the average number of children of interior pages.
+func (o *Object) Fanout() float64 {
+	if o.InteriorPages > 0 {
+		return float64(o.InteriorPages+o.LeafPages-1) / float64(o.InteriorPages)
+	}
+	return 0
+}
+
+func (o *Object) add(x *Object) {
+	o.Depth = max(o.Depth, x.Depth)
+	o.InteriorPages += x.InteriorPages
+	o.LeafPages += x.LeafPages
+	o.OverflowPages += x.OverflowPages
+	o.Entries += x.Entries
+	o.OverflowEntries += x.OverflowEntries
+	o.Payload += x.Payload
+	o.MaxPayload = max(o.MaxPayload, x.MaxPayload)
+	o.Overhead += x.Overhead
+	o.InteriorUnused += x.InteriorUnused
+	o.LeafUnused += x.LeafUnused
+	o.OverflowUnused += x.OverflowUnused
+	o.Gaps += x.Gaps
+}
+
+// Analyze reports how the database uses disk space.
+//
+// The embedded binary must be compiled with SQLITE_ENABLE_DBSTAT_VTAB,
+// otherwise Analyze returns an error.
+func Analyze(c *sqlite3.Conn, opts *Options) (*Report, error) {
+	var o Options
+	if opts != nil {
+		o = *opts
+	}
+	if o.Schema == "" {
+		o.Schema = "main"
+	}
+
+	if ok, err := hasDBStat(c); err != nil {
+		return nil, err
+	} else if !ok {
+		return nil, errors.New("analyzer: SQLite binary compiled without SQLITE_ENABLE_DBSTAT_VTAB")
+	}
+
+	err := c.Exec(`SAVEPOINT analyzer`)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Exec(`RELEASE analyzer`)
+
+	r := Report{Schema: o.Schema}
+	for _, p := range []struct {
+		name string
+		ptr  *int64
+	}{
+		{"page_size", &r.PageSize},
+		{"page_count", &r.PageCount},
+		{"freelist_count", &r.FreePages},
+	} {
+		*p.ptr, err = pragma(c, o.Schema, p.name)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	objects, err := listObjects(c, o.Schema)
+	if err != nil {
+		return nil, err
+	}
+	if err := scan(c, o.Schema, objects); err != nil {
+		return nil, err
+	}
+
+	for _, obj := range objects {
+		obj.Overhead = obj.Pages()*r.PageSize - obj.Payload - obj.Unused()
+		r.Total.add(obj)
+		r.Objects = append(r.Objects, *obj)
+	}
+	slices.SortStableFunc(r.Objects, func(a, b Object) int {
+		return cmp.Compare(b.Pages(), a.Pages())
+	})
+	return &r, nil
+}
+
+func pragma(c *sqlite3.Conn, schema, name string) (int64, error) {
+	stmt, _, err := c.Prepare(`PRAGMA ` + sqlite3.QuoteIdentifier(schema) + `.` + name)
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+
+	if !stmt.Step() {
+		return 0, stmt.Err()
+	}
+	return stmt.ColumnInt64(0), stmt.Close()
+}
+
+func hasDBStat(c *sqlite3.Conn) (bool, error) {
+	stmt, _, err := c.Prepare(`SELECT sqlite_compileoption_used('ENABLE_DBSTAT_VTAB')`)
+	if err != nil {
+		return false, err
+	}
+	defer stmt.Close()
+
+	if !stmt.Step() {
+		return false, stmt.Err()
+	}
+	return stmt.ColumnBool(0), stmt.Close()
+}
+
+func listObjects(c *sqlite3.Conn, schema string) (map[string]*Object, error) {
+	stmt, _, err := c.Prepare(`SELECT name, tbl_name, type FROM ` +
+		sqlite3.QuoteIdentifier(schema) + `.sqlite_schema WHERE rootpage > 0`)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	objects := map[string]*Object{
+		"sqlite_schema": {Name: "sqlite_schema", Table: "sqlite_schema"},
+	}
+	for stmt.Step() {
+		name := stmt.ColumnText(0)
+		objects[name] = &Object{
+			Name:  name,
+			Table: stmt.ColumnText(1),
+			Index: stmt.ColumnText(2) == "index",
+		}
+	}
+	if err := stmt.Err(); err != nil {
+		return nil, err
+	}
+	return objects, stmt.Close()
+}
+
+func scan(c *sqlite3.Conn, schema string, objects map[string]*Object) error {
+	stmt, _, err := c.Prepare(`
+		SELECT name, path, pageno, pagetype, ncell, payload, unused, mx_payload
+		FROM dbstat(?)`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	err = stmt.BindText(1, schema)
+	if err != nil {
+		return err
+	}
+
+	// dbstat visits the pages of each B-tree in order.
+	var obj *Object
+	var prev int64
+	for stmt.Step() {
+		name := stmt.ColumnText(0)
+		if obj == nil || obj.Name != name {
+			obj = objects[name]
+			if obj == nil {
+				obj = &Object{Name: name, Table: name}
+				objects[name] = obj
+			}
+			prev = 0
+		}
+
+		path := stmt.ColumnText(1)
+		pageno := stmt.ColumnInt64(2)
+		ncell := stmt.ColumnInt64(4)
+		payload := stmt.ColumnInt64(5)
+		unused := stmt.ColumnInt64(6)
+
+		if prev != 0 && pageno != prev+1 {
+			obj.Gaps++
+		}
+		prev = pageno
+
+		obj.Payload += payload
+		switch stmt.ColumnText(3) {
+		case "internal":
+			obj.InteriorPages++
+			obj.InteriorUnused += unused
+			if obj.Index {
+				// Index interior pages also hold entries.
+				obj.Entries += ncell
+			}
+		case "leaf":
+			obj.LeafPages++
+			obj.LeafUnused += unused
+			obj.Entries += ncell
+			obj.MaxPayload = max(obj.MaxPayload, stmt.ColumnInt64(7))
+		case "overflow":
+			obj.OverflowPages++
+			obj.OverflowUnused += unused
+			if strings.HasSuffix(path, "+000000") {
+				obj.OverflowEntries++
+			}
+		}
+		if !strings.Contains(path, "+") {
+			obj.Depth = max(obj.Depth, strings.Count(path, "/"))
+		}
+	}
+	if err := stmt.Err(); err != nil {
+		return err
+	}
+	return stmt.Close()
+}
+
+// WriteText writes the report to w as text,
+// in a format similar to that of sqlite3_analyzer.
+// For JSON, use [encoding/json].
+func (r *Report) WriteText(w io.Writer) error {
+	p := printer{w: w}
+	p.title(fmt.Sprintf("Disk-Space Utilization Report For %s", r.Schema))
+	p.int("Page size in bytes", r.PageSize)
+	p.int("Pages in the whole file", r.PageCount)
+	p.pct("Pages on the freelist", r.FreePages, r.PageCount)
+	p.int("Number of tables and indexes", int64(len(r.Objects)))
+
+	p.title("Page counts for all tables and indexes")
+	for _, o := range r.Objects {
+		p.pct(strings.ToUpper(o.Name), o.Pages(), r.PageCount)
+	}
+
+	p.object("All tables and indexes", &r.Total, r)
+	for _, o := range r.Objects {
+		kind := "Table"
+		if o.Index {
+			kind = "Index"
+		}
+		p.object(fmt.Sprintf("%s %s", kind, strings.ToUpper(o.Name)), &o, r)
+	}
+	return p.err
+}
+
+type printer struct {
+	w   io.Writer
+	err error
+}
+
+func (p *printer) printf(format string, a ...any) {
+	if p.err == nil {
+		_, p.err = fmt.Fprintf(p.w, format, a...)
+	}
+}
+
+func (p *printer) title(s string) {
+	p.printf("\n*** %s %s\n\n", s, strings.Repeat("*", max(0, 74-len(s))))
+}
+
+func (p *printer) label(s string) {
+	p.printf("%s%s ", s, strings.Repeat(".", max(3, 50-len(s))))
+}
+
+func (p *printer) int(label string, v int64) {
+	p.label(label)
+	p.printf("%d\n", v)
+}
+
+func (p *printer) float(label string, v float64) {
+	p.label(label)
+	p.printf("%.2f\n", v)
+}
+
+func (p *printer) pct(label string, v, total int64) {
+	p.label(label)
+	var f float64
+	if total > 0 {
+		f = 100 * float64(v) / float64(total)
+	}
+	p.printf("%-12d %5.1f%%\n", v, f)
+}
+
+func (p *printer) object(title string, o *Object, r *Report) {
+	bytes := o.Pages() * r.PageSize
+	p.title(title)
+	if o.Name != "" && o.Index {
+		p.printf("%s is an index on %s\n\n", strings.ToUpper(o.Name), strings.ToUpper(o.Table))
+	}
+	p.pct("Percentage of total database", o.Pages(), r.PageCount)
+	p.int("Number of entries", o.Entries)
+	p.int("Bytes of storage consumed", bytes)
+	p.pct("Bytes of payload", o.Payload, bytes)
+	p.pct("Bytes of metadata", o.Overhead, bytes)
+	if o.Depth > 0 {
+		p.int("B-tree depth", int64(o.Depth))
+	}
+	if o.Entries > 0 {
+		p.float("Average payload per entry", float64(o.Payload)/float64(o.Entries))
+		p.float("Average unused bytes per entry", float64(o.Unused())/float64(o.Entries))
+	}
+	if o.InteriorPages > 0 {
+		p.float("Average fanout", o.Fanout())
+	}
+	p.label("Non-sequential pages")
+	p.printf("%.1f%%\n", o.Fragmentation())
+	p.int("Maximum payload per entry", o.MaxPayload)
+	p.pct("Entries that use overflow", o.OverflowEntries, o.Entries)
+	p.int("Index pages used", o.InteriorPages)
+	p.int("Primary pages used", o.LeafPages)
+	p.int("Overflow pages used", o.OverflowPages)
+	p.int("Total pages used", o.Pages())
+	p.pct("Unused bytes on index pages", o.InteriorUnused, o.InteriorPages*r.PageSize)
+	p.pct("Unused bytes on primary pages", o.LeafUnused, o.LeafPages*r.PageSize)
+	p.pct("Unused bytes on overflow pages", o.OverflowUnused, o.OverflowPages*r.PageSize)
+	p.pct("Unused bytes on all pages", o.Unused(), bytes)
+}
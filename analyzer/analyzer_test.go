@@ -0,0 +1,82 @@
+package analyzer_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/ncruces/go-sqlite3"
+	"github.com/ncruces/go-sqlite3/analyzer"
+	_ "github.com/ncruces/go-sqlite3/embed"
+	_ "github.com/ncruces/go-sqlite3/vfs/memdb"
+)
+
+func TestAnalyze(t *testing.T) {
+	t.Parallel()
+
+	db, err := sqlite3.Open("file:/analyzer.db?vfs=memdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	err = db.Exec(`
+		CREATE TABLE t (id INTEGER PRIMARY KEY, x);
+		CREATE INDEX t_x ON t (x);
+		WITH RECURSIVE c(i) AS (SELECT 1 UNION ALL SELECT i+1 FROM c WHERE i < 1000)
+		INSERT INTO t (x) SELECT hex(randomblob(50)) FROM c;
+		INSERT INTO t (x) VALUES (randomblob(10000));
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := analyzer.Analyze(db, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if r.Total.Pages()+r.FreePages != r.PageCount {
+		t.Errorf("got %d+%d pages, want %d", r.Total.Pages(), r.FreePages, r.PageCount)
+	}
+
+	var tab, idx *analyzer.Object
+	for i := range r.Objects {
+		switch r.Objects[i].Name {
+		case "t":
+			tab = &r.Objects[i]
+		case "t_x":
+			idx = &r.Objects[i]
+		}
+	}
+	if tab == nil || idx == nil {
+		t.Fatal("missing objects")
+	}
+	if tab.Entries != 1001 {
+		t.Errorf("got %d entries, want 1001", tab.Entries)
+	}
+	if tab.Depth < 2 || tab.Fanout() <= 1 {
+		t.Errorf("got depth %d, fanout %f", tab.Depth, tab.Fanout())
+	}
+	if tab.OverflowEntries != 1 || tab.OverflowPages == 0 {
+		t.Errorf("got %d overflow entries", tab.OverflowEntries)
+	}
+	if !idx.Index || idx.Table != "t" {
+		t.Errorf("got %+v", idx)
+	}
+
+	var buf bytes.Buffer
+	err = r.WriteText(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "Index T_X") {
+		t.Error(buf.String())
+	}
+
+	_, err = json.Marshal(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -1,5 +1,11 @@
 package sqlite3
 
+import (
+	"context"
+	"errors"
+	"time"
+)
+
 // Backup is an handle to an ongoing online backup operation.
 //
 // https://sqlite.org/c3ref/backup.html
@@ -33,11 +39,7 @@ func (src *Conn) Backup(srcDB, dstURI string) error {
 //
 // https://sqlite.org/backup.html
 func (dst *Conn) Restore(dstDB, srcURI string) error {
-	src, err := dst.openDB(srcURI, OPEN_READONLY|OPEN_URI)
-	if err != nil {
-		return err
-	}
-	b, err := dst.backupInit(dst.handle, dstDB, src, "main")
+	b, err := dst.restoreInit(dstDB, srcURI)
 	if err != nil {
 		return err
 	}
@@ -61,6 +63,14 @@ func (src *Conn) BackupInit(srcDB, dstURI string) (*Backup, error) {
 	return src.backupInit(dst, "main", src.handle, srcDB)
 }
 
+func (dst *Conn) restoreInit(dstDB, srcURI string) (*Backup, error) {
+	src, err := dst.openDB(srcURI, OPEN_READONLY|OPEN_URI)
+	if err != nil {
+		return nil, err
+	}
+	return dst.backupInit(dst.handle, dstDB, src, "main")
+}
+
 func (c *Conn) backupInit(dst uint32, dstName string, src uint32, srcName string) (*Backup, error) {
 	defer c.arena.mark()()
 	dstPtr := c.arena.string(dstName)
@@ -132,3 +142,102 @@ func (b *Backup) PageCount() int {
 	r := b.c.call("sqlite3_backup_pagecount", uint64(b.handle))
 	return int(int32(r))
 }
+
+// BackupOptions configure [Conn.BackupContext] and [Conn.RestoreContext].
+type BackupOptions struct {
+	// Pages is the number of pages copied at each step.
+	// If zero, 100 pages are copied at each step.
+	// If negative, all pages are copied in a single step.
+	Pages int
+	// Sleep is the time to wait between steps,
+	// giving other connections a chance to use the source database.
+	// Steps that fail because a database is busy or locked
+	// are retried after Sleep, or 100 milliseconds if Sleep is shorter.
+	Sleep time.Duration
+	// Progress, if not nil, is called after each step.
+	Progress func(remaining, pageCount int)
+}
+
+// BackupContext backs up srcDB on the src connection to the "main" database in dstURI.
+//
+// BackupContext copies opts.Pages at a time, calling opts.Progress after each step,
+// and sleeping for opts.Sleep between steps.
+// Steps that fail because the source database is busy or locked are retried.
+// If ctx is canceled, the backup is stopped, and ctx.Err() is returned.
+//
+// https://sqlite.org/backup.html
+func (src *Conn) BackupContext(ctx context.Context, srcDB, dstURI string, opts *BackupOptions) error {
+	b, err := src.BackupInit(srcDB, dstURI)
+	if err != nil {
+		return err
+	}
+	return b.run(ctx, opts)
+}
+
+// RestoreContext restores dstDB on the dst connection from the "main" database in srcURI.
+//
+// RestoreContext steps through the restore like [Conn.BackupContext].
+//
+// https://sqlite.org/backup.html
+func (dst *Conn) RestoreContext(ctx context.Context, dstDB, srcURI string, opts *BackupOptions) error {
+	b, err := dst.restoreInit(dstDB, srcURI)
+	if err != nil {
+		return err
+	}
+	return b.run(ctx, opts)
+}
+
+// busyDelay is how long to wait before retrying a busy step,
+// same as the sqlite3 shell.
+const busyDelay = 100 * time.Millisecond
+
+func (b *Backup) run(ctx context.Context, opts *BackupOptions) (err error) {
+	defer func() {
+		if cerr := b.Close(); err == nil {
+			err = cerr
+		}
+	}()
+
+	var o BackupOptions
+	if opts != nil {
+		o = *opts
+	}
+	if o.Pages == 0 {
+		o.Pages = 100
+	}
+
+	var timer *time.Timer
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		delay := o.Sleep
+		done, err := b.Step(o.Pages)
+		if errors.Is(err, BUSY) || errors.Is(err, LOCKED) {
+			delay = max(delay, busyDelay)
+		} else if err != nil {
+			return err
+		}
+		if o.Progress != nil {
+			o.Progress(b.Remaining(), b.PageCount())
+		}
+		if done {
+			return nil
+		}
+
+		if delay > 0 {
+			if timer == nil {
+				timer = time.NewTimer(delay)
+				defer timer.Stop()
+			} else {
+				timer.Reset(delay)
+			}
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-timer.C:
+			}
+		}
+	}
+}
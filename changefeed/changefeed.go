@@ -0,0 +1,322 @@
+// Package changefeed publishes the changes made to a database
+// to subscribers in the same process.
+//
+// [Attach] installs update, commit, rollback and statement hooks,
+// and an authorizer, on a connection.
+// Changes are buffered until the transaction ends:
+// they're published to the subscribers of the database file
+// once the statement that commits the transaction finishes,
+// and discarded when the transaction, a savepoint,
+// or a statement that fails, rolls back.
+// Changes made by all attached connections to the same file
+// are published to the same subscribers.
+//
+// Only changes to tables with a rowid are published
+// (see [sqlite3.Conn.UpdateHook]).
+package changefeed
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/ncruces/go-sqlite3"
+)
+
+// ErrLagged is the error of a [Subscription] that was closed
+// because its subscriber didn't keep up with changes.
+var ErrLagged = errors.New("changefeed: subscriber lagged")
+
+// Change is a row change.
+type Change struct {
+	Table  string
+	RowID  int64
+	Action sqlite3.AuthorizerActionCode // AUTH_INSERT, AUTH_UPDATE or AUTH_DELETE
+}
+
+var (
+	mtx      sync.Mutex
+	brokers  = map[string]*broker{}
+	attached = map[*sqlite3.Conn]*attachment{}
+)
+
+type broker struct {
+	name  string
+	conns int
+	subs  map[*Subscription]struct{}
+}
+
+type attachment struct {
+	broker *broker
+}
+
+func key(filename string) string {
+	if abs, err := filepath.Abs(filename); err == nil {
+		return abs
+	}
+	return filepath.Clean(filename)
+}
+
+// must be called with mtx held.
+func getBroker(filename string) *broker {
+	name := key(filename)
+	b := brokers[name]
+	if b == nil {
+		b = &broker{name: name, subs: map[*Subscription]struct{}{}}
+		brokers[name] = b
+	}
+	return b
+}
+
+// must be called with mtx held.
+func (b *broker) release() {
+	if b.conns == 0 && len(b.subs) == 0 {
+		delete(brokers, b.name)
+	}
+}
+
+// Attach publishes changes to the main database of c.
+//
+// Attach replaces the update, commit, rollback and statement hooks,
+// and the authorizer, of c.
+// Changes are published when the statement that commits them finishes
+// (see [sqlite3.Conn.StmtHook]).
+// Changes made by a statement that fails are discarded,
+// unless the statement keeps them (see ON CONFLICT FAIL).
+// The authorizer tracks savepoints, to discard changes undone by ROLLBACK TO.
+func Attach(c *sqlite3.Conn) error {
+	name := c.Filename("main")
+	if name == nil {
+		return errors.New("changefeed: database has no filename")
+	}
+
+	mtx.Lock()
+	defer mtx.Unlock()
+	if attached[c] != nil {
+		return nil
+	}
+
+	// savepoint marks the number of changes pending
+	// when a savepoint was opened.
+	type savepoint struct {
+		name    string
+		pending int
+	}
+	var (
+		pending []Change
+		savepts []savepoint
+		// mark is the number of changes pending
+		// when the last statement started.
+		mark   int
+		commit bool
+	)
+
+	b := getBroker(name.String())
+
+	// end publishes pending changes if the transaction committed,
+	// and reports whether the transaction ended.
+	end := func() bool {
+		if !c.GetAutocommit() || c.TxnState("main") == sqlite3.TXN_WRITE {
+			return false
+		}
+		// If the transaction was rolled back,
+		// the rollback hook cleared pending.
+		if commit && len(pending) > 0 {
+			b.publish(pending)
+		}
+		pending = nil
+		savepts = nil
+		commit = false
+		return true
+	}
+
+	err := c.SetAuthorizer(func(action sqlite3.AuthorizerActionCode, op, savept, _, _ string) sqlite3.AuthorizerReturnCode {
+		if commit {
+			// sqlite3_exec prepares each statement
+			// after the previous one finishes.
+			end()
+		}
+		switch {
+		case action != sqlite3.AUTH_SAVEPOINT:
+		case op == "BEGIN":
+			savepts = append(savepts, savepoint{savept, len(pending)})
+		default:
+			for i := len(savepts) - 1; i >= 0; i-- {
+				if !strings.EqualFold(savepts[i].name, savept) {
+					continue
+				}
+				if op == "ROLLBACK" {
+					// ROLLBACK TO keeps the savepoint open.
+					pending = pending[:savepts[i].pending]
+					savepts = savepts[:i+1]
+				} else {
+					savepts = savepts[:i]
+				}
+				break
+			}
+		}
+		// Statements are authorized when they're prepared,
+		// which sqlite3_exec does right before running each one.
+		mark = len(pending)
+		return sqlite3.AUTH_OK
+	})
+	if err != nil {
+		b.release()
+		return err
+	}
+
+	b.conns++
+	attached[c] = &attachment{broker: b}
+
+	c.UpdateHook(func(action sqlite3.AuthorizerActionCode, schema, table string, rowid int64) {
+		if schema == "main" {
+			pending = append(pending, Change{Table: table, RowID: rowid, Action: action})
+		}
+	})
+	c.CommitHook(func() bool {
+		// The commit can still fail, or be busy.
+		commit = true
+		return true
+	})
+	c.RollbackHook(func() {
+		pending = nil
+		savepts = nil
+		mark = 0
+		commit = false
+	})
+	c.StmtHook(func(err error) {
+		if !end() && err != nil && c.Changes() == 0 {
+			// The statement failed, and was rolled back.
+			pending = pending[:mark]
+		}
+		// A COMMIT that is busy leaves the transaction open,
+		// and runs the commit hook again when retried.
+		commit = false
+		mark = len(pending)
+	})
+	return nil
+}
+
+// Detach stops publishing changes made by c,
+// and removes its hooks and authorizer.
+func Detach(c *sqlite3.Conn) {
+	mtx.Lock()
+	defer mtx.Unlock()
+	a := attached[c]
+	if a == nil {
+		return
+	}
+	delete(attached, c)
+	a.broker.conns--
+	a.broker.release()
+
+	c.UpdateHook(nil)
+	c.CommitHook(nil)
+	c.RollbackHook(nil)
+	c.StmtHook(nil)
+	c.SetAuthorizer(nil)
+}
+
+func (b *broker) publish(changes []Change) {
+	mtx.Lock()
+	defer mtx.Unlock()
+	for s := range b.subs {
+		batch := changes
+		if s.tables != nil {
+			batch = nil
+			for _, c := range changes {
+				if s.tables[c.Table] {
+					batch = append(batch, c)
+				}
+			}
+			if batch == nil {
+				continue
+			}
+		}
+		select {
+		case s.c <- batch:
+		default:
+			s.err = ErrLagged
+			s.close()
+		}
+	}
+}
+
+// SubscribeOptions configure a [Subscription].
+type SubscribeOptions struct {
+	// Tables filters changes by table name.
+	// If empty, changes to all tables are received.
+	Tables []string
+	// Buffer is the number of transactions that can be buffered
+	// before the subscriber is considered lagged.
+	// If zero, 16 is used.
+	Buffer int
+}
+
+// Subscription receives the changes to a database file.
+type Subscription struct {
+	// C receives the changes of each committed transaction.
+	// It is closed when the subscription is closed.
+	C <-chan []Change
+
+	c      chan []Change
+	b      *broker
+	tables map[string]bool
+	err    error
+	closed bool
+}
+
+// Subscribe subscribes to changes to the database file filename,
+// made by attached connections.
+// Subscribers must not modify the received changes.
+func Subscribe(filename string, opts *SubscribeOptions) *Subscription {
+	var o SubscribeOptions
+	if opts != nil {
+		o = *opts
+	}
+	if o.Buffer == 0 {
+		o.Buffer = 16
+	}
+
+	s := &Subscription{c: make(chan []Change, o.Buffer)}
+	s.C = s.c
+	if len(o.Tables) > 0 {
+		s.tables = map[string]bool{}
+		for _, t := range o.Tables {
+			s.tables[t] = true
+		}
+	}
+
+	mtx.Lock()
+	defer mtx.Unlock()
+	s.b = getBroker(filename)
+	s.b.subs[s] = struct{}{}
+	return s
+}
+
+// Close closes the subscription.
+func (s *Subscription) Close() {
+	mtx.Lock()
+	defer mtx.Unlock()
+	s.close()
+}
+
+// must be called with mtx held.
+func (s *Subscription) close() {
+	if !s.closed {
+		s.closed = true
+		close(s.c)
+		delete(s.b.subs, s)
+		s.b.release()
+	}
+}
+
+// Err returns [ErrLagged] if the subscription was closed
+// because its subscriber didn't keep up with changes.
+// Call it after C is closed.
+func (s *Subscription) Err() error {
+	mtx.Lock()
+	defer mtx.Unlock()
+	return s.err
+}
@@ -0,0 +1,282 @@
+package changefeed_test
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/ncruces/go-sqlite3"
+	"github.com/ncruces/go-sqlite3/changefeed"
+	_ "github.com/ncruces/go-sqlite3/embed"
+)
+
+func TestSubscribe(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "test.db")
+	db1, err := sqlite3.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db1.Close()
+
+	db2, err := sqlite3.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db2.Close()
+
+	err = db1.Exec(`CREATE TABLE a (x); CREATE TABLE b (x);`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, db := range []*sqlite3.Conn{db1, db2} {
+		err = changefeed.Attach(db)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer changefeed.Detach(db)
+	}
+
+	all := changefeed.Subscribe(path, nil)
+	defer all.Close()
+	onlyB := changefeed.Subscribe(path, &changefeed.SubscribeOptions{Tables: []string{"b"}})
+	defer onlyB.Close()
+
+	err = db1.Exec(`
+		BEGIN;
+		INSERT INTO a VALUES (1);
+		ROLLBACK;
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db1.Exec(`
+		BEGIN;
+		INSERT INTO a VALUES (1);
+		INSERT INTO b VALUES (2);
+		COMMIT;
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db2.Exec(`UPDATE a SET x = 3`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := [][]changefeed.Change{
+		{{Table: "a", RowID: 1, Action: sqlite3.AUTH_INSERT}, {Table: "b", RowID: 1, Action: sqlite3.AUTH_INSERT}},
+		{{Table: "a", RowID: 1, Action: sqlite3.AUTH_UPDATE}},
+	}
+	for _, w := range want {
+		if got := <-all.C; !reflect.DeepEqual(got, w) {
+			t.Errorf("got %v, want %v", got, w)
+		}
+	}
+	if got := <-onlyB.C; !reflect.DeepEqual(got, want[0][1:]) {
+		t.Errorf("got %v, want %v", got, want[0][1:])
+	}
+	select {
+	case got := <-onlyB.C:
+		t.Errorf("got %v, want nothing", got)
+	default:
+	}
+}
+
+func TestSubscribe_lagged(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "test.db")
+	db, err := sqlite3.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	err = db.Exec(`PRAGMA journal_mode=WAL`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = changefeed.Attach(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer changefeed.Detach(db)
+
+	s := changefeed.Subscribe(path, &changefeed.SubscribeOptions{Buffer: 1})
+	defer s.Close()
+
+	err = db.Exec(`
+		CREATE TABLE t (x);
+		INSERT INTO t VALUES (1);
+		INSERT INTO t VALUES (2);
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var n int
+	for range s.C {
+		n++
+	}
+	if n != 1 {
+		t.Errorf("got %d, want 1", n)
+	}
+	if err := s.Err(); err != changefeed.ErrLagged {
+		t.Errorf("got %v, want ErrLagged", err)
+	}
+}
+
+func TestSubscribe_rollback(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "test.db")
+	db, err := sqlite3.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	err = db.Exec(`PRAGMA journal_mode=WAL; CREATE TABLE t (x INTEGER PRIMARY KEY);`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = changefeed.Attach(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer changefeed.Detach(db)
+
+	s := changefeed.Subscribe(path, nil)
+	defer s.Close()
+
+	// Changes undone by ROLLBACK TO are discarded.
+	err = db.Exec(`
+		BEGIN;
+		INSERT INTO t VALUES (1);
+		SAVEPOINT a;
+		INSERT INTO t VALUES (2);
+		SAVEPOINT b;
+		INSERT INTO t VALUES (3);
+		RELEASE b;
+		ROLLBACK TO a;
+		INSERT INTO t VALUES (4);
+		RELEASE a;
+		COMMIT;
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Changes made by a statement that fails are discarded.
+	err = db.Exec(`BEGIN; INSERT INTO t VALUES (5), (6), (5);`)
+	if !errors.Is(err, sqlite3.CONSTRAINT) {
+		t.Errorf("got %v, want CONSTRAINT", err)
+	}
+	stmt, _, err := db.Prepare(`INSERT INTO t VALUES (?)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stmt.Close()
+	for _, x := range []int{7, 7, 8} {
+		stmt.BindInt(1, x)
+		stmt.Exec()
+	}
+	err = db.Exec(`COMMIT`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Changes are discarded when the transaction rolls back.
+	err = db.Exec(`BEGIN; INSERT INTO t VALUES (9); ROLLBACK;`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.Exec(`INSERT INTO t VALUES (10)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := [][]changefeed.Change{
+		{{Table: "t", RowID: 1, Action: sqlite3.AUTH_INSERT}, {Table: "t", RowID: 4, Action: sqlite3.AUTH_INSERT}},
+		{{Table: "t", RowID: 7, Action: sqlite3.AUTH_INSERT}, {Table: "t", RowID: 8, Action: sqlite3.AUTH_INSERT}},
+		{{Table: "t", RowID: 10, Action: sqlite3.AUTH_INSERT}},
+	}
+	for _, w := range want {
+		if got := <-s.C; !reflect.DeepEqual(got, w) {
+			t.Errorf("got %v, want %v", got, w)
+		}
+	}
+	select {
+	case got := <-s.C:
+		t.Errorf("got %v, want nothing", got)
+	default:
+	}
+}
+
+func TestSubscribe_busy(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "test.db")
+	db1, err := sqlite3.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db1.Close()
+
+	db2, err := sqlite3.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db2.Close()
+
+	err = db1.Exec(`CREATE TABLE t (x)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = changefeed.Attach(db1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer changefeed.Detach(db1)
+
+	s := changefeed.Subscribe(path, nil)
+	defer s.Close()
+
+	// A reader blocks the commit of a rollback journal.
+	stmt, _, err := db2.Prepare(`SELECT * FROM sqlite_schema`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stmt.Close()
+	if !stmt.Step() {
+		t.Fatal(stmt.Err())
+	}
+
+	err = db1.Exec(`BEGIN; INSERT INTO t VALUES (1); COMMIT;`)
+	if !errors.Is(err, sqlite3.BUSY) {
+		t.Errorf("got %v, want BUSY", err)
+	}
+	select {
+	case got := <-s.C:
+		t.Errorf("got %v, want nothing", got)
+	default:
+	}
+
+	err = stmt.Reset()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db1.Exec(`COMMIT`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []changefeed.Change{{Table: "t", RowID: 1, Action: sqlite3.AUTH_INSERT}}
+	if got := <-s.C; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
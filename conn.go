@@ -30,6 +30,7 @@ type Conn struct {
 	commit     func() bool
 	rollback   func()
 	wal        func(*Conn, string, int) error
+	stmt       func(error)
 	arena      arena
 
 	handle uint32
@@ -162,7 +163,11 @@ func (c *Conn) Exec(sql string) error {
 	sqlPtr := c.arena.string(sql)
 
 	r := c.call("sqlite3_exec", uint64(c.handle), uint64(sqlPtr), 0, 0, 0)
-	return c.error(r, sql)
+	err := c.error(r, sql)
+	if c.stmt != nil {
+		c.stmt(err)
+	}
+	return err
 }
 
 // Prepare calls [Conn.PrepareFlags] with no flags.
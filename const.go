@@ -346,6 +346,29 @@ const (
 	TXN_WRITE TxnState = 2
 )
 
+// FTS5TokenizeFlag are the flags passed to [FTS5Tokenizer.Tokenize],
+// that give the reason tokenization is being requested.
+//
+// https://sqlite.org/fts5.html#custom_tokenizers
+type FTS5TokenizeFlag uint32
+
+const (
+	FTS5_TOKENIZE_QUERY    FTS5TokenizeFlag = 0x0001
+	FTS5_TOKENIZE_PREFIX   FTS5TokenizeFlag = 0x0002
+	FTS5_TOKENIZE_DOCUMENT FTS5TokenizeFlag = 0x0004
+	FTS5_TOKENIZE_AUX      FTS5TokenizeFlag = 0x0008
+)
+
+// FTS5TokenFlag are the flags that can be passed
+// to the token callback of [FTS5Tokenizer.Tokenize].
+//
+// https://sqlite.org/fts5.html#synonym_support
+type FTS5TokenFlag uint32
+
+const (
+	FTS5_TOKEN_COLOCATED FTS5TokenFlag = 0x0001
+)
+
 // Datatype is a fundamental datatype of SQLite.
 //
 // https://sqlite.org/c3ref/c_blob.html
@@ -181,6 +181,26 @@ func (ctx Context) ResultJSON(value any) {
 	ctx.ResultRawText(data)
 }
 
+// ResultRawJSON sets the result of the function to JSON text,
+// with a subtype of 'J', so that it is treated as JSON
+// (rather than as text) by the JSON SQL functions.
+// The function should be registered with [RESULT_SUBTYPE].
+//
+// https://sqlite.org/json1.html#value_arguments
+func (ctx Context) ResultRawJSON(data []byte) {
+	ctx.ResultRawText(data)
+	ctx.ResultSubtype('J')
+}
+
+// ResultSubtype sets the subtype of the result of the function.
+// The function should be registered with [RESULT_SUBTYPE].
+//
+// https://sqlite.org/c3ref/result_subtype.html
+func (ctx Context) ResultSubtype(t uint) {
+	ctx.c.call("sqlite3_result_subtype",
+		uint64(ctx.handle), uint64(uint32(t)))
+}
+
 // ResultValue sets the result of the function to a copy of [Value].
 //
 // https://sqlite.org/c3ref/result_blob.html
@@ -27,6 +27,40 @@
 //   - "sqlite" encodes as SQLite and decodes any [format] supported by SQLite;
 //   - "rfc3339" encodes and decodes RFC 3339 only.
 //
+// Columns declared as DATE, TIME, DATETIME or TIMESTAMP are decoded as time values.
+// So are columns declared as INTEGER followed by one of those types
+// (e.g. INTEGER TIMESTAMP).
+// When decoding with "auto" or "sqlite", the declared type of a column
+// also selects the format:
+// DATE columns are decoded as [sqlite3.TimeFormat1] first,
+// and numbers in INTEGER DATE, TIME, DATETIME and TIMESTAMP columns
+// are decoded as [sqlite3.TimeFormatUnix].
+//
+// The time zone for time values without a time zone offset
+// can be specified using "_loc":
+//
+//	sql.Open("sqlite3", "file:demo.db?_loc=auto")
+//
+// Possible values are: "auto" (the local time zone), "UTC",
+// or any IANA Time Zone name (as accepted by [time.LoadLocation]).
+// Decoded time values are converted to this time zone,
+// and time values encoded by formats [sqlite3.TimeFormat1]
+// through [sqlite3.TimeFormat10] use the wall clock in this time zone.
+// By default, time values without a time zone offset are decoded as UTC,
+// decoded time values keep the offset they were stored with,
+// and time values are encoded unchanged.
+//
+// The JSON encoding of [sqlite3.JSON] values can be specified using "_jsonfmt":
+//
+//	sql.Open("sqlite3", "file:demo.db?_jsonfmt=jsonb")
+//
+// Possible values are: "text" (the default), "jsonb";
+//   - "text" stores JSON text;
+//   - "jsonb" stores [JSONB] blobs.
+//
+// [sqlite3.JSON] always decodes JSON text;
+// use [sqlite3.JSONB] to store and decode JSONB blobs.
+//
 // [PRAGMA] statements can be specified using "_pragma":
 //
 //	sql.Open("sqlite3", "file:demo.db?_pragma=busy_timeout(10000)")
@@ -38,6 +72,7 @@
 //
 // [URI]: https://sqlite.org/uri.html
 // [PRAGMA]: https://sqlite.org/pragma.html
+// [JSONB]: https://sqlite.org/jsonb.html
 // [TRANSACTION]: https://sqlite.org/lang_transaction.html#deferred_immediate_and_exclusive_transactions
 // [linearizable]: https://pkg.go.dev/database/sql#TxOptions
 // [serializable]: https://pkg.go.dev/database/sql#TxOptions
@@ -78,7 +113,18 @@ func init() {
 // The [sqlite3.Conn] can be used to execute queries, register functions, etc.
 // Any error returned closes the connection and is returned to [database/sql].
 func Open(dataSourceName string, init func(*sqlite3.Conn) error) (*sql.DB, error) {
-	c, err := (&SQLite{init}).OpenConnector(dataSourceName)
+	return OpenTerm(dataSourceName, init, nil)
+}
+
+// OpenTerm opens the SQLite database specified by dataSourceName as a [database/sql.DB].
+//
+// Like [Open], the init function is called by the driver on new connections.
+// The term function is called by the driver before closing connections
+// (e.g. to run [PRAGMA optimize]).
+//
+// [PRAGMA optimize]: https://sqlite.org/pragma.html#pragma_optimize
+func OpenTerm(dataSourceName string, init, term func(*sqlite3.Conn) error) (*sql.DB, error) {
+	c, err := (&SQLite{Init: init, Term: term}).OpenConnector(dataSourceName)
 	if err != nil {
 		return nil, err
 	}
@@ -87,7 +133,14 @@ func Open(dataSourceName string, init func(*sqlite3.Conn) error) (*sql.DB, error
 
 // SQLite implements [database/sql/driver.Driver].
 type SQLite struct {
-	init func(*sqlite3.Conn) error
+	// Init function is called by the driver on new connections.
+	// The [sqlite3.Conn] can be used to execute queries, register functions, etc.
+	// Any error returned closes the connection and is returned to [database/sql].
+	Init func(*sqlite3.Conn) error
+
+	// Term function is called by the driver before closing connections.
+	// The [sqlite3.Conn] can be used to execute queries.
+	Term func(*sqlite3.Conn) error
 }
 
 // Open implements [database/sql/driver.Driver].
@@ -107,7 +160,7 @@ func (d *SQLite) OpenConnector(name string) (driver.Connector, error) {
 func (d *SQLite) newConnector(name string) (*connector, error) {
 	c := connector{driver: d, name: name}
 
-	var txlock, timefmt string
+	var txlock, timefmt, loc, jsonfmt string
 	if strings.HasPrefix(name, "file:") {
 		if _, after, ok := strings.Cut(name, "?"); ok {
 			query, err := url.ParseQuery(after)
@@ -116,6 +169,8 @@ func (d *SQLite) newConnector(name string) (*connector, error) {
 			}
 			txlock = query.Get("_txlock")
 			timefmt = query.Get("_timefmt")
+			loc = query.Get("_loc")
+			jsonfmt = query.Get("_jsonfmt")
 			c.pragmas = query.Has("_pragma")
 		}
 	}
@@ -141,6 +196,28 @@ func (d *SQLite) newConnector(name string) (*connector, error) {
 		c.tmRead = sqlite3.TimeFormat(timefmt)
 		c.tmWrite = sqlite3.TimeFormat(timefmt)
 	}
+
+	switch loc {
+	case "":
+		// keep stored offsets
+	case "auto":
+		c.tmLoc = time.Local
+	default:
+		var err error
+		c.tmLoc, err = time.LoadLocation(loc)
+		if err != nil {
+			return nil, fmt.Errorf("sqlite3: invalid _loc: %s", loc)
+		}
+	}
+
+	switch jsonfmt {
+	case "", "text":
+		// use JSON text
+	case "jsonb":
+		c.jsonb = true
+	default:
+		return nil, fmt.Errorf("sqlite3: invalid _jsonfmt: %s", jsonfmt)
+	}
 	return &c, nil
 }
 
@@ -150,6 +227,8 @@ type connector struct {
 	txLock  string
 	tmRead  sqlite3.TimeFormat
 	tmWrite sqlite3.TimeFormat
+	tmLoc   *time.Location
+	jsonb   bool
 	pragmas bool
 }
 
@@ -159,9 +238,13 @@ func (n *connector) Driver() driver.Driver {
 
 func (n *connector) Connect(ctx context.Context) (_ driver.Conn, err error) {
 	c := &conn{
-		txLock:  n.txLock,
-		tmRead:  n.tmRead,
-		tmWrite: n.tmWrite,
+		txLock:   n.txLock,
+		tmRead:   n.tmRead,
+		tmWrite:  n.tmWrite,
+		tmLoc:    n.tmLoc,
+		jsonb:    n.jsonb,
+		term:     n.driver.Term,
+		readOnly: '0',
 	}
 
 	c.Conn, err = sqlite3.Open(n.name)
@@ -170,7 +253,7 @@ func (n *connector) Connect(ctx context.Context) (_ driver.Conn, err error) {
 	}
 	defer func() {
 		if err != nil {
-			c.Close()
+			c.Conn.Close()
 		}
 	}()
 
@@ -183,13 +266,13 @@ func (n *connector) Connect(ctx context.Context) (_ driver.Conn, err error) {
 			return nil, err
 		}
 	}
-	if n.driver.init != nil {
-		err = n.driver.init(c.Conn)
+	if n.driver.Init != nil {
+		err = n.driver.Init(c.Conn)
 		if err != nil {
 			return nil, err
 		}
 	}
-	if n.pragmas || n.driver.init != nil {
+	if n.pragmas || n.driver.Init != nil {
 		s, _, err := c.Conn.Prepare(`PRAGMA query_only`)
 		if err != nil {
 			return nil, err
@@ -209,11 +292,15 @@ func (n *connector) Connect(ctx context.Context) (_ driver.Conn, err error) {
 
 type conn struct {
 	*sqlite3.Conn
+	term     func(*sqlite3.Conn) error
 	txLock   string
 	txReset  string
 	tmRead   sqlite3.TimeFormat
 	tmWrite  sqlite3.TimeFormat
+	tmLoc    *time.Location
+	jsonb    bool
 	readOnly byte
+	broken   bool
 }
 
 var (
@@ -221,6 +308,8 @@ var (
 	_ driver.ConnPrepareContext = &conn{}
 	_ driver.ExecerContext      = &conn{}
 	_ driver.ConnBeginTx        = &conn{}
+	_ driver.SessionResetter    = &conn{}
+	_ driver.Validator          = &conn{}
 	_ sqlite3.DriverConn        = &conn{}
 )
 
@@ -228,6 +317,44 @@ func (c *conn) Raw() *sqlite3.Conn {
 	return c.Conn
 }
 
+func (c *conn) Close() error {
+	var err error
+	if c.term != nil {
+		c.Conn.SetInterrupt(nil)
+		err = c.term(c.Conn)
+	}
+	return errors.Join(err, c.Conn.Close())
+}
+
+// ResetSession is called by [database/sql] before reusing a connection.
+// It rolls back any transaction left open,
+// restores PRAGMA query_only, and clears the interrupt context.
+func (c *conn) ResetSession(ctx context.Context) error {
+	if c.broken {
+		return driver.ErrBadConn
+	}
+
+	c.Conn.SetInterrupt(nil)
+
+	if !c.Conn.GetAutocommit() {
+		if err := c.Conn.Exec(`ROLLBACK`); err != nil {
+			c.broken = true
+			return driver.ErrBadConn
+		}
+	}
+	if err := c.Conn.Exec(`PRAGMA query_only=` + string(c.readOnly)); err != nil {
+		c.broken = true
+		return driver.ErrBadConn
+	}
+	return nil
+}
+
+// IsValid is called by [database/sql] before returning a connection
+// to the pool; connections left in a broken state are discarded.
+func (c *conn) IsValid() bool {
+	return !c.broken
+}
+
 // Deprecated: use BeginTx instead.
 func (c *conn) Begin() (driver.Tx, error) {
 	// notest
@@ -281,6 +408,9 @@ func (c *conn) Rollback() error {
 		defer c.Conn.SetInterrupt(old)
 		err = c.Conn.Exec(`ROLLBACK` + c.txReset)
 	}
+	if err != nil && !c.Conn.GetAutocommit() {
+		c.broken = true
+	}
 	return err
 }
 
@@ -301,7 +431,7 @@ func (c *conn) PrepareContext(ctx context.Context, query string) (driver.Stmt, e
 		s.Close()
 		return nil, util.TailErr
 	}
-	return &stmt{Stmt: s, tmRead: c.tmRead, tmWrite: c.tmWrite, inputs: -2}, nil
+	return &stmt{Stmt: s, tmRead: c.tmRead, tmWrite: c.tmWrite, tmLoc: c.tmLoc, jsonb: c.jsonb, inputs: -2}, nil
 }
 
 func (c *conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
@@ -337,6 +467,8 @@ type stmt struct {
 	*sqlite3.Stmt
 	tmWrite sqlite3.TimeFormat
 	tmRead  sqlite3.TimeFormat
+	tmLoc   *time.Location
+	jsonb   bool
 	inputs  int
 }
 
@@ -430,9 +562,15 @@ func (s *stmt) setupBindings(args []driver.NamedValue) (err error) {
 			case sqlite3.ZeroBlob:
 				err = s.Stmt.BindZeroBlob(id, int64(a))
 			case time.Time:
-				err = s.Stmt.BindTime(id, a, s.tmWrite)
+				err = s.Stmt.BindTime(id, wallTime(a, s.tmWrite, s.tmLoc), s.tmWrite)
 			case util.JSON:
-				err = s.Stmt.BindJSON(id, a.Value)
+				if s.jsonb {
+					err = s.Stmt.BindJSONB(id, a.Value)
+				} else {
+					err = s.Stmt.BindJSON(id, a.Value)
+				}
+			case util.JSONB:
+				err = s.Stmt.BindJSONB(id, a.Value)
 			case util.PointerUnwrap:
 				err = s.Stmt.BindPointer(id, util.UnwrapPointer(a))
 			case nil:
@@ -452,7 +590,7 @@ func (s *stmt) CheckNamedValue(arg *driver.NamedValue) error {
 	switch arg.Value.(type) {
 	case bool, int, int64, float64, string, []byte,
 		time.Time, sqlite3.ZeroBlob,
-		util.JSON, util.PointerUnwrap,
+		util.JSON, util.JSONB, util.PointerUnwrap,
 		nil:
 		return nil
 	default:
@@ -574,12 +712,20 @@ func (r *rows) decodeTime(i int, v any) (_ time.Time, ok bool) {
 	default:
 		return
 	}
-	switch r.declType(i) {
-	case "DATE", "TIME", "DATETIME", "TIMESTAMP":
-		// could be a time value
-	default:
+	decltype, unix := timeType(r.declType(i))
+	if decltype == "" {
 		return
 	}
+	if r.tmRead == sqlite3.TimeFormatAuto {
+		if f := columnFormat(decltype, unix, v); f != "" {
+			if t, err := f.Decode(v); err == nil {
+				return inLocation(t, v, r.tmLoc), true
+			}
+		}
+	}
 	t, err := r.tmRead.Decode(v)
-	return t, err == nil
+	if err != nil {
+		return
+	}
+	return inLocation(t, v, r.tmLoc), true
 }
@@ -129,6 +129,82 @@ func Test_Open_txLock_invalid(t *testing.T) {
 	}
 }
 
+func Test_Open_callbacks(t *testing.T) {
+	t.Parallel()
+
+	var opened, closed int
+	db, err := OpenTerm(":memory:",
+		func(c *sqlite3.Conn) error {
+			opened++
+			return nil
+		},
+		func(c *sqlite3.Conn) error {
+			closed++
+			return c.Exec(`PRAGMA optimize`)
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = db.Exec(`CREATE TABLE test (col)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opened != 1 || closed != 1 {
+		t.Errorf("got %d opened, %d closed", opened, closed)
+	}
+}
+
+func Test_ResetSession(t *testing.T) {
+	t.Parallel()
+
+	db, err := sql.Open("sqlite3", "file:/reset.db?vfs=memdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	_, err = db.Exec(`CREATE TABLE test (col)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Leave a transaction open, and the connection query only.
+	_, err = db.Exec(`BEGIN; INSERT INTO test VALUES (1); PRAGMA query_only=1`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = db.Exec(`INSERT INTO test VALUES (2)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var count int
+	err = db.QueryRow(`SELECT count(*) FROM test`).Scan(&count)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 {
+		t.Errorf("got %d rows, want 1", count)
+	}
+
+	var readOnly bool
+	err = db.QueryRow(`PRAGMA query_only`).Scan(&readOnly)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if readOnly {
+		t.Error("want query_only off")
+	}
+}
+
 func Test_BeginTx(t *testing.T) {
 	if !vfs.SupportsFileLocking {
 		t.Skip("skipping without locks")
@@ -340,3 +416,97 @@ func Test_time(t *testing.T) {
 		})
 	}
 }
+
+func Test_time_loc(t *testing.T) {
+	t.Parallel()
+
+	loc := time.FixedZone("", -4*3600)
+	db, err := sql.Open("sqlite3", "file::memory:?_timefmt=sqlite&_loc=UTC")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	_, err = sql.Open("sqlite3", "file::memory:?_loc=Mars/Olympus_Mons")
+	if err == nil {
+		t.Error("want error")
+	}
+
+	c, err := db.Conn(context.TODO())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	// Replace the location, as tests can't rely on tzdata.
+	err = c.Raw(func(driverConn any) error {
+		driverConn.(*conn).tmLoc = loc
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = c.ExecContext(context.TODO(), `CREATE TABLE test (at DATETIME)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := time.Date(2022, 2, 22, 22, 22, 22, 0, loc)
+	_, err = c.ExecContext(context.TODO(), `INSERT INTO test VALUES (?)`, want)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var text string
+	err = c.QueryRowContext(context.TODO(), `SELECT CAST(at AS TEXT) FROM test`).Scan(&text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text != "2022-02-22 22:22:22" {
+		t.Errorf("got %q", text)
+	}
+
+	var got time.Time
+	err = c.QueryRowContext(context.TODO(), `SELECT at FROM test`).Scan(&got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.Equal(want) || got.Location() != loc {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func Test_time_decltype(t *testing.T) {
+	t.Parallel()
+
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`
+		CREATE TABLE test (day DATE, at INTEGER TIMESTAMP, jd TIMESTAMP);
+		INSERT INTO test VALUES ('2022-02-22', 86400, 2459000);
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var day, at, jd time.Time
+	err = db.QueryRow(`SELECT day, at, jd FROM test`).Scan(&day, &at, &jd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2022, 2, 22, 0, 0, 0, 0, time.UTC); !day.Equal(want) {
+		t.Errorf("got %v, want %v", day, want)
+	}
+	if want := time.Unix(86400, 0); !at.Equal(want) {
+		t.Errorf("got %v, want %v", at, want)
+	}
+	// Numbers in other time columns are julian days, if in range.
+	if want := time.Date(2020, 5, 30, 12, 0, 0, 0, time.UTC); !jd.Equal(want) {
+		t.Errorf("got %v, want %v", jd, want)
+	}
+}
@@ -0,0 +1,131 @@
+package driver
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/ncruces/go-sqlite3"
+)
+
+// ReadWriteDB is a coordinated pair of [database/sql.DB] handles
+// to the same database in [WAL mode]:
+// a Writer with a single connection that starts "immediate" transactions,
+// and a pool of "query_only" Reader connections.
+//
+// With a single writer, write transactions queue in the Writer pool,
+// instead of contending for locks and failing with [sqlite3.BUSY].
+//
+// [WAL mode]: https://sqlite.org/wal.html
+type ReadWriteDB struct {
+	Writer *sql.DB
+	Reader *sql.DB
+}
+
+// OpenReadWrite opens the SQLite database specified by dataSourceName
+// as a [ReadWriteDB].
+//
+// The Writer uses "_txlock=immediate", and a single connection;
+// Reader connections are "query_only".
+// The database is put in WAL mode before the first Reader connection is opened.
+//
+// The init and term functions are called for both Writer and Reader connections,
+// as described for [OpenTerm]; either may be nil.
+func OpenReadWrite(dataSourceName string, init, term func(*sqlite3.Conn) error) (*ReadWriteDB, error) {
+	writer := &SQLite{Term: term, Init: func(c *sqlite3.Conn) error {
+		err := c.Exec(`PRAGMA journal_mode=wal`)
+		if err == nil && init != nil {
+			err = init(c)
+		}
+		return err
+	}}
+	reader := &SQLite{Term: term, Init: func(c *sqlite3.Conn) error {
+		var err error
+		if init != nil {
+			err = init(c)
+		}
+		if err == nil {
+			err = c.Exec(`PRAGMA query_only=1`)
+		}
+		return err
+	}}
+
+	w, err := writer.newConnector(dataSourceName)
+	if err != nil {
+		return nil, err
+	}
+	w.txLock = "immediate"
+
+	r, err := reader.newConnector(dataSourceName)
+	if err != nil {
+		return nil, err
+	}
+
+	db := &ReadWriteDB{
+		Writer: sql.OpenDB(w),
+		Reader: sql.OpenDB(r),
+	}
+	db.Writer.SetMaxOpenConns(1)
+
+	// Setup WAL mode before opening any readers.
+	if err := db.Writer.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// Close closes both the Writer and the Reader.
+func (db *ReadWriteDB) Close() error {
+	return errors.Join(db.Reader.Close(), db.Writer.Close())
+}
+
+// Begin starts a write transaction on the Writer.
+func (db *ReadWriteDB) Begin() (*sql.Tx, error) {
+	return db.BeginTx(context.Background(), nil)
+}
+
+// BeginTx starts a transaction:
+// read-only transactions use the Reader, other transactions use the Writer.
+func (db *ReadWriteDB) BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error) {
+	if opts != nil && opts.ReadOnly {
+		return db.Reader.BeginTx(ctx, opts)
+	}
+	return db.Writer.BeginTx(ctx, opts)
+}
+
+// Exec executes a query on the Writer.
+func (db *ReadWriteDB) Exec(query string, args ...any) (sql.Result, error) {
+	return db.ExecContext(context.Background(), query, args...)
+}
+
+// ExecContext executes a query on the Writer.
+func (db *ReadWriteDB) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return db.Writer.ExecContext(ctx, query, args...)
+}
+
+// Query executes a query on the Reader.
+// Queries that modify the database (e.g. with a RETURNING clause)
+// must use the Writer.
+func (db *ReadWriteDB) Query(query string, args ...any) (*sql.Rows, error) {
+	return db.QueryContext(context.Background(), query, args...)
+}
+
+// QueryContext executes a query on the Reader.
+// Queries that modify the database (e.g. with a RETURNING clause)
+// must use the Writer.
+func (db *ReadWriteDB) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	return db.Reader.QueryContext(ctx, query, args...)
+}
+
+// QueryRow executes a query that is expected to return at most one row
+// on the Reader.
+func (db *ReadWriteDB) QueryRow(query string, args ...any) *sql.Row {
+	return db.QueryRowContext(context.Background(), query, args...)
+}
+
+// QueryRowContext executes a query that is expected to return at most one row
+// on the Reader.
+func (db *ReadWriteDB) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	return db.Reader.QueryRowContext(ctx, query, args...)
+}
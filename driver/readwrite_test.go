@@ -0,0 +1,84 @@
+package driver
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/ncruces/go-sqlite3"
+	"github.com/ncruces/go-sqlite3/vfs"
+)
+
+func Test_OpenReadWrite(t *testing.T) {
+	if !vfs.SupportsSharedMemory {
+		t.Skip("skipping without shared memory")
+	}
+	t.Parallel()
+
+	db, err := OpenReadWrite("file:"+
+		filepath.ToSlash(filepath.Join(t.TempDir(), "test.db")), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	var mode string
+	err = db.QueryRow(`PRAGMA journal_mode`).Scan(&mode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode != "wal" {
+		t.Errorf("got %q, want wal", mode)
+	}
+
+	_, err = db.Exec(`CREATE TABLE test (col)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = tx.Exec(`INSERT INTO test VALUES (1)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Readers are not blocked by the writer.
+	var count int
+	err = db.QueryRow(`SELECT count(*) FROM test`).Scan(&count)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 0 {
+		t.Errorf("got %d rows, want 0", count)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rtx, err := db.BeginTx(context.TODO(), &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rtx.Rollback()
+
+	err = rtx.QueryRow(`SELECT count(*) FROM test`).Scan(&count)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 {
+		t.Errorf("got %d rows, want 1", count)
+	}
+
+	// Readers are query only.
+	_, err = db.Reader.Exec(`INSERT INTO test VALUES (2)`)
+	if !errors.Is(err, sqlite3.READONLY) {
+		t.Errorf("got %v, want sqlite3.READONLY", err)
+	}
+}
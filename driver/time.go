@@ -1,7 +1,10 @@
 package driver
 
 import (
+	"strings"
 	"time"
+
+	"github.com/ncruces/go-sqlite3"
 )
 
 // Convert a string in [time.RFC3339Nano] format into a [time.Time]
@@ -29,3 +32,81 @@ func maybeTime(text string) (_ time.Time, _ bool) {
 	}
 	return
 }
+
+// Check if a declared type is a time type,
+// and if it's declared as an INTEGER, for Unix timestamps.
+func timeType(decltype string) (_ string, unix bool) {
+	if num, typ, ok := strings.Cut(decltype, " "); ok && num == "INTEGER" {
+		decltype = strings.TrimSpace(typ)
+		unix = true
+	}
+	switch decltype {
+	case "DATE", "TIME", "DATETIME", "TIMESTAMP":
+		return decltype, unix
+	}
+	return "", false
+}
+
+// Select a format to decode a time value
+// based on the declared type of its column.
+func columnFormat(decltype string, unix bool, v any) sqlite3.TimeFormat {
+	switch v.(type) {
+	case string:
+		if decltype == "DATE" {
+			return sqlite3.TimeFormat1
+		}
+	case int64, float64:
+		if unix {
+			return sqlite3.TimeFormatUnix
+		}
+	}
+	return ""
+}
+
+// Interpret a decoded time value in loc.
+// Text without a time zone offset is in loc's wall clock,
+// other values are converted to loc.
+func inLocation(t time.Time, v any, loc *time.Location) time.Time {
+	if loc == nil {
+		return t
+	}
+	if s, ok := v.(string); ok && !hasZone(s) {
+		year, month, day := t.Date()
+		hour, minute, sec := t.Clock()
+		return time.Date(year, month, day, hour, minute, sec, t.Nanosecond(), loc)
+	}
+	return t.In(loc)
+}
+
+// Shift a time value to loc's wall clock,
+// for formats that convert to UTC before encoding.
+func wallTime(t time.Time, f sqlite3.TimeFormat, loc *time.Location) time.Time {
+	if loc == nil {
+		return t
+	}
+	switch f {
+	case
+		sqlite3.TimeFormat1, sqlite3.TimeFormat2,
+		sqlite3.TimeFormat3, sqlite3.TimeFormat4,
+		sqlite3.TimeFormat5, sqlite3.TimeFormat6,
+		sqlite3.TimeFormat7, sqlite3.TimeFormat8,
+		sqlite3.TimeFormat9, sqlite3.TimeFormat10:
+		t = t.In(loc)
+		year, month, day := t.Date()
+		hour, minute, sec := t.Clock()
+		return time.Date(year, month, day, hour, minute, sec, t.Nanosecond(), time.UTC)
+	}
+	return t
+}
+
+// Check if a time value ends in a time zone indicator:
+// "Z" or an offset of the form "±HH:MM".
+func hasZone(s string) bool {
+	if n := len(s); n > 0 && (s[n-1] == 'Z' || s[n-1] == 'z') {
+		return true
+	}
+	if n := len(s); n >= 6 && (s[n-6] == '+' || s[n-6] == '-') && s[n-3] == ':' {
+		return true
+	}
+	return false
+}
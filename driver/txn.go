@@ -0,0 +1,38 @@
+package driver
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/ncruces/go-sqlite3"
+)
+
+// WithTx runs fn in a transaction started with opts,
+// committing if fn returns nil, rolling back otherwise.
+//
+// If the transaction fails with a retryable error
+// ([sqlite3.BUSY], [sqlite3.BUSY_SNAPSHOT], [sqlite3.LOCKED], etc.),
+// it is rolled back, and fn is run again after a delay,
+// as configured by retry.
+//
+// See [sqlite3.Conn.WithTx] and [sqlite3.RetryPolicy.Retry].
+func WithTx(ctx context.Context, db *sql.DB, opts *sql.TxOptions, fn func(*sql.Tx) error, retry ...sqlite3.RetryPolicy) error {
+	var policy sqlite3.RetryPolicy
+	if len(retry) > 0 {
+		policy = retry[0]
+	}
+
+	return policy.Retry(ctx, func() error {
+		tx, err := db.BeginTx(ctx, opts)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		err = fn(tx)
+		if err != nil {
+			return err
+		}
+		return tx.Commit()
+	})
+}
@@ -0,0 +1,85 @@
+package driver
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/ncruces/go-sqlite3"
+)
+
+func Test_WithTx(t *testing.T) {
+	t.Parallel()
+
+	db, err := Open("file:/withtx.db?vfs=memdb", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`CREATE TABLE test (col)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var retries int
+	policy := sqlite3.RetryPolicy{
+		MaxAttempts: 3,
+		OnRetry: func(attempt int, err error) {
+			retries = attempt
+		},
+	}
+
+	// Retry, then succeed.
+	err = WithTx(context.TODO(), db, nil, func(tx *sql.Tx) error {
+		_, err := tx.Exec(`INSERT INTO test VALUES (1)`)
+		if err == nil && retries == 0 {
+			err = sqlite3.BUSY
+		}
+		return err
+	}, policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if retries != 1 {
+		t.Errorf("got %d retries, want 1", retries)
+	}
+
+	// Give up after MaxAttempts.
+	retries = 0
+	err = WithTx(context.TODO(), db, nil, func(tx *sql.Tx) error {
+		return sqlite3.LOCKED
+	}, policy)
+	if !errors.Is(err, sqlite3.LOCKED) {
+		t.Errorf("got %v, want sqlite3.LOCKED", err)
+	}
+	if retries != 2 {
+		t.Errorf("got %d retries, want 2", retries)
+	}
+
+	// Don't retry other errors.
+	retries = 0
+	err = WithTx(context.TODO(), db, nil, func(tx *sql.Tx) error {
+		_, err := tx.Exec(`INSERT INTO test VALUES (2)`)
+		if err == nil {
+			err = sqlite3.CONSTRAINT
+		}
+		return err
+	}, policy)
+	if !errors.Is(err, sqlite3.CONSTRAINT) {
+		t.Errorf("got %v, want sqlite3.CONSTRAINT", err)
+	}
+	if retries != 0 {
+		t.Errorf("got %d retries, want 0", retries)
+	}
+
+	var count int
+	err = db.QueryRow(`SELECT count(*) FROM test`).Scan(&count)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 {
+		t.Errorf("got %d rows, want 1", count)
+	}
+}
@@ -0,0 +1,395 @@
+package sqlite3
+
+import (
+	"bufio"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// DumpOptions configure [Conn.Dump].
+type DumpOptions struct {
+	// Schema is the name of the database to dump.
+	// If empty, "main" is used.
+	Schema string
+	// Tables restricts the dump to the named tables,
+	// and the indexes, triggers and views with the same names
+	// or on those tables.
+	Tables []string
+	// SchemaOnly dumps only the schema.
+	SchemaOnly bool
+	// DataOnly dumps only the data.
+	DataOnly bool
+}
+
+// Dump writes the schema and content of a database to w as SQL text,
+// like the .dump command of the [sqlite3] shell.
+//
+// The dump is taken inside a savepoint, so it is consistent.
+// Use [Conn.ExecScript] to restore it.
+//
+// [sqlite3]: https://sqlite.org/cli.html
+func (c *Conn) Dump(w io.Writer, opts *DumpOptions) (err error) {
+	var o DumpOptions
+	if opts != nil {
+		o = *opts
+	}
+	if o.Schema == "" {
+		o.Schema = "main"
+	}
+
+	sp := c.Savepoint()
+	defer sp.Release(&err)
+
+	d := dumper{c: c, w: bufio.NewWriter(w), opts: o}
+	if !o.DataOnly {
+		d.print("PRAGMA foreign_keys=OFF;\nBEGIN TRANSACTION;\n")
+	}
+	if err := d.tables(); err != nil {
+		return err
+	}
+	if !o.DataOnly {
+		if err := d.objects(); err != nil {
+			return err
+		}
+		if d.writable {
+			d.print("PRAGMA writable_schema=RESET;\n")
+		}
+		d.print("COMMIT;\n")
+	}
+	if d.err != nil {
+		return d.err
+	}
+	return d.w.Flush()
+}
+
+type dumper struct {
+	c        *Conn
+	w        *bufio.Writer
+	opts     DumpOptions
+	writable bool
+	err      error
+}
+
+func (d *dumper) filter() string {
+	if len(d.opts.Tables) == 0 {
+		return ""
+	}
+	var names []string
+	for _, name := range d.opts.Tables {
+		names = append(names, Quote(name))
+	}
+	list := strings.Join(names, ",")
+	return ` AND (name IN (` + list + `) OR tbl_name IN (` + list + `))`
+}
+
+func (d *dumper) tables() error {
+	stmt, _, err := d.c.Prepare(`SELECT name, sql FROM ` + QuoteIdentifier(d.opts.Schema) + `.sqlite_schema
+		WHERE type = 'table' AND sql NOT NULL` + d.filter() + `
+		ORDER BY tbl_name = 'sqlite_sequence', rowid`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for stmt.Step() {
+		name := stmt.ColumnText(0)
+		sql := stmt.ColumnText(1)
+
+		switch {
+		case name == "sqlite_sequence":
+			if !d.opts.SchemaOnly {
+				d.print("DELETE FROM sqlite_sequence;\n")
+			}
+		case strings.HasPrefix(name, "sqlite_stat"):
+			if !d.opts.SchemaOnly {
+				d.print("ANALYZE sqlite_schema;\n")
+			}
+		case strings.HasPrefix(name, "sqlite_"):
+			continue
+		case strings.HasPrefix(sql, "CREATE VIRTUAL TABLE"):
+			// Virtual tables are created without calling xCreate,
+			// their shadow tables are dumped as regular tables.
+			if !d.opts.DataOnly {
+				if !d.writable {
+					d.print("PRAGMA writable_schema=ON;\n")
+					d.writable = true
+				}
+				d.print("INSERT INTO sqlite_schema(type,name,tbl_name,rootpage,sql)VALUES('table',%s,%s,0,%s);\n",
+					Quote(name), Quote(name), Quote(sql))
+			}
+			continue
+		default:
+			if !d.opts.DataOnly {
+				d.print("%s;\n", sql)
+			}
+		}
+
+		if !d.opts.SchemaOnly {
+			if err := d.rows(name); err != nil {
+				return err
+			}
+		}
+	}
+	return stmt.Err()
+}
+
+func (d *dumper) rows(table string) error {
+	// Generated columns can't be inserted into,
+	// list the remaining columns if there are any.
+	stmt, _, err := d.c.Prepare(`SELECT name, hidden FROM pragma_table_xinfo(?, ?)`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	stmt.BindText(1, table)
+	stmt.BindText(2, d.opts.Schema)
+
+	var cols []string
+	var generated bool
+	for stmt.Step() {
+		if stmt.ColumnInt(1) == 0 {
+			cols = append(cols, QuoteIdentifier(stmt.ColumnText(0)))
+		} else {
+			generated = true
+		}
+	}
+	if err := stmt.Err(); err != nil {
+		return err
+	}
+
+	insert := `INSERT INTO ` + QuoteIdentifier(table)
+	if generated {
+		insert += `(` + strings.Join(cols, ",") + `)`
+	}
+	insert += ` VALUES(`
+
+	rows, _, err := d.c.Prepare(`SELECT ` + strings.Join(cols, ",") +
+		` FROM ` + QuoteIdentifier(d.opts.Schema) + `.` + QuoteIdentifier(table))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Step() && d.err == nil {
+		d.w.WriteString(insert)
+		for i := range cols {
+			if i > 0 {
+				d.w.WriteByte(',')
+			}
+			d.w.WriteString(dumpValue(rows, i))
+		}
+		_, d.err = d.w.WriteString(");\n")
+	}
+	return rows.Err()
+}
+
+func (d *dumper) objects() error {
+	stmt, _, err := d.c.Prepare(`SELECT sql FROM ` + QuoteIdentifier(d.opts.Schema) + `.sqlite_schema
+		WHERE type IN ('index', 'trigger', 'view') AND sql NOT NULL` + d.filter() + `
+		ORDER BY type = 'view', rowid`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for stmt.Step() {
+		d.print("%s;\n", stmt.ColumnText(0))
+	}
+	return stmt.Err()
+}
+
+func (d *dumper) print(format string, args ...any) {
+	if d.err != nil {
+		return
+	}
+	for _, arg := range args {
+		i := strings.Index(format, "%s")
+		d.w.WriteString(format[:i])
+		d.w.WriteString(arg.(string))
+		format = format[i+2:]
+	}
+	_, d.err = d.w.WriteString(format)
+}
+
+// dumpValue quotes a column value so that it round trips
+// with the same datatype.
+func dumpValue(stmt *Stmt, col int) string {
+	switch stmt.ColumnType(col) {
+	case INTEGER:
+		return strconv.FormatInt(stmt.ColumnInt64(col), 10)
+	case FLOAT:
+		f := stmt.ColumnFloat(col)
+		s := Quote(f)
+		if !math.IsInf(f, 0) && !strings.ContainsAny(s, ".e") {
+			s += ".0"
+		}
+		return s
+	case TEXT:
+		s := stmt.ColumnRawText(col)
+		for _, b := range s {
+			if b == 0 {
+				return "CAST(" + Quote(s) + " AS TEXT)"
+			}
+		}
+		return Quote(string(s))
+	case BLOB:
+		return Quote(stmt.ColumnRawBlob(col))
+	}
+	return "NULL"
+}
+
+// ExecScript executes the SQL statements read from r,
+// one statement at a time, without loading the entire script into memory.
+// Use it to restore the output of [Conn.Dump].
+//
+// If progress is not nil, it is called after each statement
+// with the number of bytes read so far.
+// If progress returns an error, execution stops and the error is returned.
+func (c *Conn) ExecScript(r io.Reader, progress func(read int64) error) error {
+	br := bufio.NewReader(r)
+
+	var read int64
+	var sql strings.Builder
+	for {
+		line, err := br.ReadString('\n')
+		read += int64(len(line))
+		sql.WriteString(line)
+
+		if err == nil && !complete(sql.String()) {
+			continue
+		}
+		if err != nil && err != io.EOF {
+			return err
+		}
+
+		tail := sql.String()
+		sql.Reset()
+		for {
+			stmt, rest, perr := c.Prepare(tail)
+			if perr != nil {
+				return perr
+			}
+			if stmt == nil {
+				break
+			}
+			perr = stmt.Exec()
+			stmt.Close()
+			if perr != nil {
+				return perr
+			}
+			if progress != nil {
+				if perr := progress(read); perr != nil {
+					return perr
+				}
+			}
+			tail = rest
+		}
+
+		if err == io.EOF {
+			return nil
+		}
+	}
+}
+
+// complete reports whether sql ends with a complete statement,
+// like [sqlite3_complete].
+//
+// [sqlite3_complete]: https://sqlite.org/c3ref/complete.html
+func complete(sql string) bool {
+	const (
+		tkSEMI = iota
+		tkWS
+		tkOTHER
+		tkEXPLAIN
+		tkCREATE
+		tkTEMP
+		tkTRIGGER
+		tkEND
+	)
+	trans := [8][8]uint8{
+		/* INVALID */ {1, 0, 2, 3, 4, 2, 2, 2},
+		/* START   */ {1, 1, 2, 3, 4, 2, 2, 2},
+		/* NORMAL  */ {1, 2, 2, 2, 2, 2, 2, 2},
+		/* EXPLAIN */ {1, 3, 3, 2, 4, 2, 2, 2},
+		/* CREATE  */ {1, 4, 2, 2, 2, 4, 5, 2},
+		/* TRIGGER */ {6, 5, 5, 5, 5, 5, 5, 5},
+		/* SEMI    */ {6, 6, 5, 5, 5, 5, 5, 7},
+		/* END     */ {1, 7, 5, 5, 5, 5, 5, 5},
+	}
+
+	var state uint8
+	for i := 0; i < len(sql); i++ {
+		var token int
+		switch c := sql[i]; c {
+		case ';':
+			token = tkSEMI
+		case ' ', '\r', '\t', '\n', '\f':
+			token = tkWS
+		case '/':
+			if i+1 >= len(sql) || sql[i+1] != '*' {
+				token = tkOTHER
+				break
+			}
+			n := strings.Index(sql[i+2:], "*/")
+			if n < 0 {
+				return false
+			}
+			i += n + 3
+			token = tkWS
+		case '-':
+			if i+1 >= len(sql) || sql[i+1] != '-' {
+				token = tkOTHER
+				break
+			}
+			n := strings.IndexByte(sql[i:], '\n')
+			if n < 0 {
+				return state == 1
+			}
+			i += n
+			token = tkWS
+		case '[', '`', '"', '\'':
+			if c == '[' {
+				c = ']'
+			}
+			n := strings.IndexByte(sql[i+1:], c)
+			if n < 0 {
+				return false
+			}
+			i += n + 1
+			token = tkOTHER
+		default:
+			if !idChar(c) {
+				token = tkOTHER
+				break
+			}
+			j := i + 1
+			for j < len(sql) && idChar(sql[j]) {
+				j++
+			}
+			switch strings.ToUpper(sql[i:j]) {
+			case "CREATE":
+				token = tkCREATE
+			case "TRIGGER":
+				token = tkTRIGGER
+			case "TEMP", "TEMPORARY":
+				token = tkTEMP
+			case "END":
+				token = tkEND
+			case "EXPLAIN":
+				token = tkEXPLAIN
+			default:
+				token = tkOTHER
+			}
+			i = j - 1
+		}
+		state = trans[state][token]
+	}
+	return state == 1
+}
+
+func idChar(c byte) bool {
+	return c >= 0x80 || c == '_' || c == '$' ||
+		'0' <= c && c <= '9' || 'a' <= c|0x20 && c|0x20 <= 'z'
+}
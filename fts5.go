@@ -0,0 +1,229 @@
+package sqlite3
+
+import (
+	"context"
+
+	"github.com/ncruces/go-sqlite3/internal/util"
+	"github.com/tetratelabs/wazero/api"
+)
+
+// CreateTokenizer registers a new FTS5 tokenizer name.
+// create is called for each FTS5 table that uses the tokenizer,
+// with any arguments given in the tokenize option.
+// If the returned tokenizer implements [io.Closer],
+// it will be called to free resources.
+//
+// https://sqlite.org/fts5.html#custom_tokenizers
+func (c *Conn) CreateTokenizer(name string, create func(args ...string) (FTS5Tokenizer, error)) error {
+	if err := c.exports("sqlite3_create_tokenizer_go"); err != nil {
+		return err
+	}
+	defer c.arena.mark()()
+	namePtr := c.arena.string(name)
+	funcPtr := util.AddHandle(c.ctx, create)
+	r := c.call("sqlite3_create_tokenizer_go",
+		uint64(c.handle), uint64(namePtr), uint64(funcPtr))
+	return c.error(r)
+}
+
+// FTS5Tokenizer is the interface implemented by FTS5 tokenizers.
+//
+// https://sqlite.org/fts5.html#custom_tokenizers
+type FTS5Tokenizer interface {
+	// Tokenize splits text into tokens,
+	// calling token for each token found.
+	// start and end are the byte offsets of the token in text.
+	// If token returns an error, Tokenize should stop and return it.
+	// Implementations must not retain text.
+	Tokenize(text []byte, flags FTS5TokenizeFlag, token func(token []byte, flags FTS5TokenFlag, start, end int) error) error
+}
+
+func fts5CreateCallback(ctx context.Context, mod api.Module, pApp, azArg, nArg, ppOut uint32) uint32 {
+	create := util.GetHandle(ctx, pApp).(func(args ...string) (FTS5Tokenizer, error))
+
+	args := make([]string, nArg)
+	for i := range args {
+		ptr := util.ReadUint32(mod, azArg+uint32(i)*ptrlen)
+		args[i] = util.ReadString(mod, ptr, _MAX_NAME)
+	}
+
+	tok, err := create(args...)
+	if err != nil {
+		_, code := errorCode(err, ERROR)
+		return code
+	}
+	util.WriteUint32(mod, ppOut, util.AddHandle(ctx, tok))
+	return _OK
+}
+
+func fts5TokenizeCallback(ctx context.Context, mod api.Module, pTok, pCtx, flags, pText, nText uint32) uint32 {
+	db := ctx.Value(connKey{}).(*Conn)
+	tok := util.GetHandle(ctx, pTok).(FTS5Tokenizer)
+
+	// Copy text, as emitting tokens may grow memory.
+	var text []byte
+	if nText > 0 {
+		text = append(text, util.View(mod, pText, uint64(nText))...)
+	}
+
+	err := tok.Tokenize(text, FTS5TokenizeFlag(flags), func(token []byte, tflags FTS5TokenFlag, start, end int) error {
+		defer db.arena.mark()()
+		ptr := db.arena.bytes(token)
+		r := db.call("sqlite3_fts5_token_go", uint64(pCtx), uint64(tflags),
+			uint64(ptr), uint64(len(token)), uint64(start), uint64(end))
+		return db.error(r)
+	})
+	_, code := errorCode(err, ERROR)
+	return code
+}
+
+// CreateFTS5Function registers a new FTS5 auxiliary function name.
+//
+// Auxiliary functions can be used in queries against an FTS5 table:
+//
+//	SELECT name(fts, ...) FROM fts WHERE fts MATCH ?
+//
+// https://sqlite.org/fts5.html#custom_auxiliary_functions
+func (c *Conn) CreateFTS5Function(name string, fn FTS5Function) error {
+	if err := c.exports("sqlite3_create_fts5_function_go"); err != nil {
+		return err
+	}
+	defer c.arena.mark()()
+	namePtr := c.arena.string(name)
+	funcPtr := util.AddHandle(c.ctx, fn)
+	r := c.call("sqlite3_create_fts5_function_go",
+		uint64(c.handle), uint64(namePtr), uint64(funcPtr))
+	return c.error(r)
+}
+
+// FTS5Function is the type of an FTS5 auxiliary function.
+// Implementations must not retain fts or arg.
+type FTS5Function func(ctx Context, fts FTS5Context, arg ...Value)
+
+// FTS5Context gives an FTS5 auxiliary function
+// access to the current row and query.
+//
+// https://sqlite.org/fts5.html#the_fts5extensionapi_structure
+type FTS5Context struct {
+	c      *Conn
+	api    uint32
+	handle uint32
+}
+
+// ColumnCount returns the number of columns in the table.
+func (fts FTS5Context) ColumnCount() int {
+	r := fts.c.call("sqlite3_fts5_column_count_go", uint64(fts.api), uint64(fts.handle))
+	return int(int32(r))
+}
+
+// RowCount returns the number of rows in the table.
+func (fts FTS5Context) RowCount() (int64, error) {
+	defer fts.c.arena.mark()()
+	ptr := fts.c.arena.new(8)
+	r := fts.c.call("sqlite3_fts5_row_count_go", uint64(fts.api), uint64(fts.handle),
+		uint64(ptr))
+	return int64(util.ReadUint64(fts.c.mod, ptr)), fts.c.error(r)
+}
+
+// ColumnTotalSize returns the total number of tokens in column col
+// of all rows in the table.
+// If col is negative, the total number of tokens in all columns is returned.
+func (fts FTS5Context) ColumnTotalSize(col int) (int64, error) {
+	defer fts.c.arena.mark()()
+	ptr := fts.c.arena.new(8)
+	r := fts.c.call("sqlite3_fts5_column_total_size_go", uint64(fts.api), uint64(fts.handle),
+		uint64(col), uint64(ptr))
+	return int64(util.ReadUint64(fts.c.mod, ptr)), fts.c.error(r)
+}
+
+// PhraseCount returns the number of phrases in the current query.
+func (fts FTS5Context) PhraseCount() int {
+	r := fts.c.call("sqlite3_fts5_phrase_count_go", uint64(fts.api), uint64(fts.handle))
+	return int(int32(r))
+}
+
+// PhraseSize returns the number of tokens in a phrase of the query.
+func (fts FTS5Context) PhraseSize(phrase int) int {
+	r := fts.c.call("sqlite3_fts5_phrase_size_go", uint64(fts.api), uint64(fts.handle),
+		uint64(phrase))
+	return int(int32(r))
+}
+
+// InstCount returns the number of phrase instances in the current row.
+func (fts FTS5Context) InstCount() (int, error) {
+	defer fts.c.arena.mark()()
+	ptr := fts.c.arena.new(4)
+	r := fts.c.call("sqlite3_fts5_inst_count_go", uint64(fts.api), uint64(fts.handle),
+		uint64(ptr))
+	return int(int32(util.ReadUint32(fts.c.mod, ptr))), fts.c.error(r)
+}
+
+// Inst returns the phrase number, column and token offset
+// of phrase instance i of the current row.
+func (fts FTS5Context) Inst(i int) (phrase, col, off int, err error) {
+	defer fts.c.arena.mark()()
+	ptr := fts.c.arena.new(12)
+	r := fts.c.call("sqlite3_fts5_inst_go", uint64(fts.api), uint64(fts.handle),
+		uint64(i), uint64(ptr+0), uint64(ptr+4), uint64(ptr+8))
+	phrase = int(int32(util.ReadUint32(fts.c.mod, ptr+0)))
+	col = int(int32(util.ReadUint32(fts.c.mod, ptr+4)))
+	off = int(int32(util.ReadUint32(fts.c.mod, ptr+8)))
+	return phrase, col, off, fts.c.error(r)
+}
+
+// RowID returns the rowid of the current row.
+func (fts FTS5Context) RowID() int64 {
+	r := fts.c.call("sqlite3_fts5_rowid_go", uint64(fts.api), uint64(fts.handle))
+	return int64(r)
+}
+
+// ColumnText returns the text of column col of the current row.
+func (fts FTS5Context) ColumnText(col int) (string, error) {
+	defer fts.c.arena.mark()()
+	ptr := fts.c.arena.new(8)
+	r := fts.c.call("sqlite3_fts5_column_text_go", uint64(fts.api), uint64(fts.handle),
+		uint64(col), uint64(ptr+0), uint64(ptr+4))
+	if err := fts.c.error(r); err != nil {
+		return "", err
+	}
+	txt := util.ReadUint32(fts.c.mod, ptr+0)
+	n := util.ReadUint32(fts.c.mod, ptr+4)
+	if n == 0 {
+		return "", nil
+	}
+	return string(util.View(fts.c.mod, txt, uint64(n))), nil
+}
+
+// ColumnSize returns the number of tokens in column col of the current row.
+// If col is negative, the number of tokens in all columns is returned.
+func (fts FTS5Context) ColumnSize(col int) (int, error) {
+	defer fts.c.arena.mark()()
+	ptr := fts.c.arena.new(4)
+	r := fts.c.call("sqlite3_fts5_column_size_go", uint64(fts.api), uint64(fts.handle),
+		uint64(col), uint64(ptr))
+	return int(int32(util.ReadUint32(fts.c.mod, ptr))), fts.c.error(r)
+}
+
+// SetAuxData saves data that is kept for the duration of the query.
+// If data implements [io.Closer], it will be called to free resources.
+func (fts FTS5Context) SetAuxData(data any) error {
+	ptr := util.AddHandle(fts.c.ctx, data)
+	r := fts.c.call("sqlite3_fts5_set_auxdata_go", uint64(fts.api), uint64(fts.handle),
+		uint64(ptr))
+	return fts.c.error(r)
+}
+
+// GetAuxData returns data saved with [FTS5Context.SetAuxData].
+func (fts FTS5Context) GetAuxData() any {
+	ptr := uint32(fts.c.call("sqlite3_fts5_get_auxdata_go", uint64(fts.api), uint64(fts.handle), 0))
+	return util.GetHandle(fts.c.ctx, ptr)
+}
+
+func fts5AuxCallback(ctx context.Context, mod api.Module, pApp, pApi, pFts, pCtx, nArg, pArg uint32) {
+	args := getFuncArgs()
+	defer putFuncArgs(args)
+	db := ctx.Value(connKey{}).(*Conn)
+	fn := util.GetHandle(db.ctx, pApp).(FTS5Function)
+	callbackArgs(db, args[:nArg], pArg)
+	fn(Context{db, pCtx}, FTS5Context{db, pApi, pFts}, args[:nArg]...)
+}
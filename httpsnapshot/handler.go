@@ -0,0 +1,283 @@
+// Package httpsnapshot serves database snapshots over HTTP.
+//
+// A [Handler] responds to GET requests with a consistent snapshot of a database,
+// and to POST requests by replacing the database with the uploaded one.
+// Snapshots are taken, and uploads restored,
+// with the [online backup API].
+//
+// [online backup API]: https://sqlite.org/backup.html
+package httpsnapshot
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ncruces/go-sqlite3"
+)
+
+// Options configure a [Handler].
+type Options struct {
+	// Schema is the name of the database to serve.
+	// If empty, "main" is used.
+	Schema string
+	// Filename is the name suggested to clients that download snapshots.
+	// If empty, "database.db" is used.
+	Filename string
+	// ReadOnly rejects uploads.
+	ReadOnly bool
+	// MaxUploadSize limits the size of uploads.
+	// If zero, the size of uploads is not limited.
+	MaxUploadSize int64
+	// TempDir is the directory used for temporary files.
+	// If empty, [os.TempDir] is used.
+	TempDir string
+}
+
+// Handler is an [http.Handler] that downloads and uploads database snapshots.
+//
+// GET and HEAD requests download a snapshot of the database,
+// and support conditional and range requests.
+// The ETag of a snapshot is derived from [sqlite3.FCNTL_DATA_VERSION],
+// and changes whenever the database changes.
+//
+// POST requests upload a database that replaces the served database,
+// atomically, if it passes an integrity check.
+// If the request has an If-Match header,
+// the database is only replaced if its ETag matches.
+type Handler struct {
+	mtx   sync.Mutex
+	conn  *sqlite3.Conn
+	opts  Options
+	nonce string
+}
+
+// New creates a handler that serves the database on c.
+// The handler serializes access to the connection,
+// which must not be used concurrently by anything else.
+func New(c *sqlite3.Conn, opts *Options) *Handler {
+	h := &Handler{conn: c}
+	if opts != nil {
+		h.opts = *opts
+	}
+	if h.opts.Schema == "" {
+		h.opts.Schema = "main"
+	}
+	if h.opts.Filename == "" {
+		h.opts.Filename = "database.db"
+	}
+
+	// Data versions restart with each connection.
+	var nonce [8]byte
+	rand.Read(nonce[:])
+	h.nonce = hex.EncodeToString(nonce[:])
+	return h
+}
+
+// ServeHTTP implements [http.Handler].
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		h.download(w, r)
+	case http.MethodPost:
+		if h.opts.ReadOnly {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, "read-only", http.StatusMethodNotAllowed)
+			return
+		}
+		h.upload(w, r)
+	default:
+		if h.opts.ReadOnly {
+			w.Header().Set("Allow", "GET, HEAD")
+		} else {
+			w.Header().Set("Allow", "GET, HEAD, POST")
+		}
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *Handler) download(w http.ResponseWriter, r *http.Request) {
+	f, err := os.CreateTemp(h.opts.TempDir, "snapshot-*.db")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	etag, err := h.snapshot(r, f.Name())
+	if errors.Is(err, errNotModified) {
+		w.Header().Set("ETag", etag)
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Content-Type", "application/vnd.sqlite3")
+	w.Header().Set("Content-Disposition", "attachment; filename="+strconv.Quote(h.opts.Filename))
+	http.ServeContent(w, r, "", time.Time{}, f)
+}
+
+var errNotModified = errors.New("httpsnapshot: not modified")
+
+// snapshot backs up the database to path, in a read transaction,
+// so that the ETag matches the snapshot.
+func (h *Handler) snapshot(r *http.Request, path string) (etag string, err error) {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	old := h.conn.SetInterrupt(r.Context())
+	defer h.conn.SetInterrupt(old)
+
+	err = h.conn.Exec(`BEGIN`)
+	if err != nil {
+		return "", err
+	}
+	defer h.conn.Exec(`COMMIT`)
+
+	etag, err = h.etag()
+	if err != nil {
+		return "", err
+	}
+	if matchETag(r.Header.Get("If-None-Match"), etag) {
+		return etag, errNotModified
+	}
+	return etag, h.conn.Backup(h.opts.Schema, path)
+}
+
+func (h *Handler) upload(w http.ResponseWriter, r *http.Request) {
+	f, err := os.CreateTemp(h.opts.TempDir, "upload-*.db")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	body := r.Body
+	if h.opts.MaxUploadSize > 0 {
+		body = http.MaxBytesReader(w, body, h.opts.MaxUploadSize)
+	}
+	_, err = io.Copy(f, body)
+	if err == nil {
+		err = f.Close()
+	}
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		} else {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
+		return
+	}
+
+	// The upload is not modified, so it's safe to open it as immutable.
+	uri := (&url.URL{Scheme: "file", Path: f.Name(), RawQuery: "immutable=1"}).String()
+	if err := integrityCheck(uri); err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	etag, err := h.restore(r, uri)
+	if errors.Is(err, errPrecondition) {
+		w.Header().Set("ETag", etag)
+		http.Error(w, err.Error(), http.StatusPreconditionFailed)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("ETag", etag)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+var errPrecondition = errors.New("httpsnapshot: precondition failed")
+
+func (h *Handler) restore(r *http.Request, uri string) (etag string, err error) {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	old := h.conn.SetInterrupt(r.Context())
+	defer h.conn.SetInterrupt(old)
+
+	if match := r.Header.Get("If-Match"); match != "" {
+		etag, err := h.etag()
+		if err != nil {
+			return "", err
+		}
+		if !matchETag(match, etag) {
+			return etag, errPrecondition
+		}
+	}
+
+	err = h.conn.Restore(h.opts.Schema, uri)
+	if err != nil {
+		return "", err
+	}
+	return h.etag()
+}
+
+func (h *Handler) etag() (string, error) {
+	// Read the schema, so that changes by other connections are noticed.
+	err := h.conn.Exec(`SELECT 1 FROM ` + sqlite3.QuoteIdentifier(h.opts.Schema) + `.sqlite_schema LIMIT 1`)
+	if err != nil {
+		return "", err
+	}
+	v, err := h.conn.FileControl(h.opts.Schema, sqlite3.FCNTL_DATA_VERSION)
+	if err != nil {
+		return "", err
+	}
+	return `"` + h.nonce + "-" + strconv.FormatUint(uint64(v.(uint32)), 10) + `"`, nil
+}
+
+func integrityCheck(uri string) error {
+	db, err := sqlite3.OpenFlags(uri, sqlite3.OPEN_READONLY|sqlite3.OPEN_URI)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	stmt, _, err := db.Prepare(`PRAGMA integrity_check`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	var msgs []string
+	for stmt.Step() {
+		msgs = append(msgs, stmt.ColumnText(0))
+	}
+	if err := stmt.Err(); err != nil {
+		return err
+	}
+	if len(msgs) != 1 || msgs[0] != "ok" {
+		return errors.New("httpsnapshot: integrity check failed: " + strings.Join(msgs, "; "))
+	}
+	return nil
+}
+
+// matchETag reports whether an If-Match or If-None-Match
+// header matches etag, using weak comparison.
+func matchETag(header, etag string) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,146 @@
+package httpsnapshot_test
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ncruces/go-sqlite3"
+	_ "github.com/ncruces/go-sqlite3/embed"
+	"github.com/ncruces/go-sqlite3/httpsnapshot"
+	_ "github.com/ncruces/go-sqlite3/vfs/memdb"
+)
+
+func TestHandler(t *testing.T) {
+	t.Parallel()
+
+	db, err := sqlite3.Open("file:/httpsnapshot.db?vfs=memdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	err = db.Exec(`CREATE TABLE t (x); INSERT INTO t VALUES (1), (2), (3);`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := httpsnapshot.New(db, &httpsnapshot.Options{TempDir: t.TempDir()})
+
+	serve := func(method string, body []byte, header ...string) *http.Response {
+		t.Helper()
+		req := httptest.NewRequest(method, "/", bytes.NewReader(body))
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec.Result()
+	}
+
+	res := serve("GET", nil)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("got %s", res.Status)
+	}
+	etag := res.Header.Get("ETag")
+	snapshot, _ := io.ReadAll(res.Body)
+	if !bytes.HasPrefix(snapshot, []byte("SQLite format 3\x00")) {
+		t.Fatal("not a database")
+	}
+	if got := count(t, snapshot); got != 3 {
+		t.Errorf("got %d rows, want 3", got)
+	}
+
+	res = serve("GET", nil, "Range", "bytes=0-15")
+	if res.StatusCode != http.StatusPartialContent {
+		t.Fatalf("got %s", res.Status)
+	}
+	if body, _ := io.ReadAll(res.Body); string(body) != "SQLite format 3\x00" {
+		t.Errorf("got %q", body)
+	}
+
+	res = serve("GET", nil, "If-None-Match", etag)
+	if res.StatusCode != http.StatusNotModified {
+		t.Fatalf("got %s", res.Status)
+	}
+
+	err = db.Exec(`INSERT INTO t VALUES (4)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res = serve("GET", nil, "If-None-Match", etag)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("got %s", res.Status)
+	}
+	if res.Header.Get("ETag") == etag {
+		t.Error("ETag did not change")
+	}
+
+	res = serve("POST", snapshot, "If-Match", etag)
+	if res.StatusCode != http.StatusPreconditionFailed {
+		t.Fatalf("got %s", res.Status)
+	}
+
+	res = serve("POST", []byte("garbage"))
+	if res.StatusCode != http.StatusUnprocessableEntity {
+		t.Fatalf("got %s", res.Status)
+	}
+
+	res = serve("POST", snapshot)
+	if res.StatusCode != http.StatusNoContent {
+		t.Fatalf("got %s", res.Status)
+	}
+
+	stmt, _, err := db.Prepare(`SELECT count(*) FROM t`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stmt.Close()
+	if !stmt.Step() {
+		t.Fatal(stmt.Err())
+	}
+	if got := stmt.ColumnInt(0); got != 3 {
+		t.Errorf("got %d rows, want 3", got)
+	}
+
+	res = serve("DELETE", nil)
+	if res.StatusCode != http.StatusMethodNotAllowed {
+		t.Fatalf("got %s", res.Status)
+	}
+	if allow := res.Header.Get("Allow"); !strings.Contains(allow, "POST") {
+		t.Errorf("got %q", allow)
+	}
+}
+
+func count(t *testing.T, data []byte) int {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "snapshot.db")
+	err := os.WriteFile(path, data, 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := sqlite3.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	stmt, _, err := db.Prepare(`SELECT count(*) FROM t`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stmt.Close()
+
+	if !stmt.Step() {
+		t.Fatal(stmt.Err())
+	}
+	return stmt.ColumnInt(0)
+}
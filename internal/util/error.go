@@ -23,6 +23,7 @@ const (
 	IsolationErr = ErrorString("sqlite3: unsupported isolation level")
 	ValueErr     = ErrorString("sqlite3: unsupported value")
 	NoVFSErr     = ErrorString("sqlite3: no such vfs: ")
+	NoExportErr  = ErrorString("sqlite3: SQLite binary doesn't export: ")
 )
 
 func AssertErr() ErrorString {
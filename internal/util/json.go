@@ -5,6 +5,8 @@ import (
 	"strconv"
 	"time"
 	"unsafe"
+
+	"github.com/ncruces/go-sqlite3/util/jsonb"
 )
 
 type JSON struct{ Value any }
@@ -33,3 +35,12 @@ func (j JSON) Scan(value any) error {
 
 	return json.Unmarshal(buf, j.Value)
 }
+
+type JSONB struct{ Value any }
+
+func (j JSONB) Scan(value any) error {
+	if buf, ok := value.([]byte); ok {
+		return jsonb.Unmarshal(buf, j.Value)
+	}
+	return JSON(j).Scan(value)
+}
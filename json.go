@@ -10,3 +10,15 @@ import "github.com/ncruces/go-sqlite3/internal/util"
 func JSON(value any) any {
 	return util.JSON{Value: value}
 }
+
+// JSONB returns a value that can be used as an argument to
+// [database/sql.DB.Exec], [database/sql.Row.Scan] and similar methods to
+// store value as [JSONB], or decode JSONB into value.
+// BLOBs are decoded as JSONB, other values as JSON.
+// JSONB should NOT be used with [Stmt.BindJSONB], [Stmt.ColumnJSONB],
+// or [Value.JSONB].
+//
+// [JSONB]: https://sqlite.org/jsonb.html
+func JSONB(value any) any {
+	return util.JSONB{Value: value}
+}
@@ -0,0 +1,249 @@
+// Package livequery re-runs queries when the tables they read change.
+//
+// [Watch] prepares a query with an authorizer that records the tables
+// and columns it reads, and subscribes to changes to those tables
+// with [changefeed.Subscribe].
+// Changes made by connections attached with [changefeed.Attach]
+// trigger a (debounced) re-execution of the query,
+// and results are delivered only if they changed.
+package livequery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"time"
+
+	"github.com/ncruces/go-sqlite3"
+	"github.com/ncruces/go-sqlite3/changefeed"
+)
+
+// Options configure a [Watch].
+type Options struct {
+	// Debounce is how long to wait for further changes
+	// before re-running the query.
+	// If zero, 50 milliseconds are used.
+	Debounce time.Duration
+}
+
+// Result is the result of a query.
+type Result struct {
+	Columns []string
+	// Rows have INTEGER values as int64, FLOAT as float64,
+	// NULL as nil, TEXT as string, and BLOB as []byte.
+	Rows [][]any
+}
+
+// Query is a watched query.
+type Query struct {
+	// C receives the results of the query: first the initial result,
+	// then a result each time it changes.
+	// It is closed when the context is done, or the query fails.
+	C <-chan Result
+
+	// Reads maps each table of the main database
+	// that the query reads to the columns it reads.
+	Reads map[string][]string
+
+	c    chan Result
+	err  error
+	done chan struct{}
+}
+
+// Watch prepares and runs the query sql, with positional arguments args,
+// and runs it again each time the tables it reads change, until ctx is done.
+//
+// Watch replaces the authorizer of c.
+// The connection must not be used for anything else until ctx is done
+// and C is closed.
+func Watch(ctx context.Context, c *sqlite3.Conn, sql string, args []any, opts *Options) (*Query, error) {
+	var o Options
+	if opts != nil {
+		o = *opts
+	}
+	if o.Debounce == 0 {
+		o.Debounce = 50 * time.Millisecond
+	}
+
+	name := c.Filename("main")
+	if name == nil {
+		return nil, errors.New("livequery: database has no filename")
+	}
+
+	reads := map[string][]string{}
+	err := c.SetAuthorizer(func(action sqlite3.AuthorizerActionCode, table, column, schema, _ string) sqlite3.AuthorizerReturnCode {
+		if action == sqlite3.AUTH_READ && schema == "main" {
+			cols := reads[table]
+			if column != "" && !slices.Contains(cols, column) {
+				cols = append(cols, column)
+			}
+			reads[table] = cols
+		}
+		return sqlite3.AUTH_OK
+	})
+	if err != nil {
+		return nil, err
+	}
+	stmt, tail, err := c.Prepare(sql)
+	if aerr := c.SetAuthorizer(nil); err == nil {
+		err = aerr
+	}
+	if err == nil && stmt == nil {
+		err = errors.New("livequery: empty statement")
+	}
+	if err == nil && tail != "" {
+		err = errors.New("livequery: multiple statements")
+	}
+	if err == nil && !stmt.ReadOnly() {
+		err = errors.New("livequery: statement is not read-only")
+	}
+	if err == nil {
+		err = bind(stmt, args)
+	}
+	if err != nil {
+		stmt.Close()
+		return nil, err
+	}
+
+	tables := make([]string, 0, len(reads))
+	for t := range reads {
+		tables = append(tables, t)
+	}
+
+	q := &Query{
+		c:     make(chan Result),
+		Reads: reads,
+		done:  make(chan struct{}),
+	}
+	q.C = q.c
+	sub := changefeed.Subscribe(name.String(), &changefeed.SubscribeOptions{Tables: tables})
+	go q.run(ctx, c, stmt, sub, name.String(), tables, o.Debounce)
+	return q, nil
+}
+
+// Err returns the error that closed C:
+// ctx.Err(), or the error that caused the query to fail.
+// It blocks until C is closed.
+func (q *Query) Err() error {
+	<-q.done
+	return q.err
+}
+
+func (q *Query) run(ctx context.Context, c *sqlite3.Conn, stmt *sqlite3.Stmt, sub *changefeed.Subscription, name string, tables []string, debounce time.Duration) {
+	defer close(q.done)
+	defer close(q.c)
+	defer func() { sub.Close() }()
+	defer stmt.Close()
+
+	old := c.SetInterrupt(ctx)
+	defer c.SetInterrupt(old)
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	var last *Result
+	for {
+		select {
+		case <-ctx.Done():
+			q.err = ctx.Err()
+			return
+
+		case _, ok := <-sub.C:
+			if !ok {
+				// The subscriber lagged, changes were lost.
+				sub = changefeed.Subscribe(name, &changefeed.SubscribeOptions{Tables: tables})
+			}
+			if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
+				}
+			}
+			timer.Reset(debounce)
+
+		case <-timer.C:
+			res, err := query(stmt)
+			if errors.Is(err, sqlite3.BUSY) {
+				timer.Reset(debounce)
+				continue
+			}
+			if err != nil {
+				if ctx.Err() != nil {
+					err = ctx.Err()
+				}
+				q.err = err
+				return
+			}
+			if last != nil && reflect.DeepEqual(last, res) {
+				continue
+			}
+			last = res
+			select {
+			case q.c <- *res:
+			case <-ctx.Done():
+				q.err = ctx.Err()
+				return
+			}
+		}
+	}
+}
+
+func query(stmt *sqlite3.Stmt) (*Result, error) {
+	defer stmt.Reset()
+
+	res := Result{Columns: make([]string, stmt.ColumnCount())}
+	for i := range res.Columns {
+		res.Columns[i] = stmt.ColumnName(i)
+	}
+	for stmt.Step() {
+		row := make([]any, len(res.Columns))
+		if err := stmt.Columns(row); err != nil {
+			return nil, err
+		}
+		for i, v := range row {
+			if b, ok := v.([]byte); ok {
+				row[i] = append([]byte{}, b...)
+			}
+		}
+		res.Rows = append(res.Rows, row)
+	}
+	if err := stmt.Err(); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+func bind(stmt *sqlite3.Stmt, args []any) error {
+	if len(args) != stmt.BindCount() {
+		return fmt.Errorf("livequery: got %d arguments, want %d", len(args), stmt.BindCount())
+	}
+	for i, arg := range args {
+		var err error
+		switch a := arg.(type) {
+		case bool:
+			err = stmt.BindBool(i+1, a)
+		case int:
+			err = stmt.BindInt(i+1, a)
+		case int64:
+			err = stmt.BindInt64(i+1, a)
+		case float64:
+			err = stmt.BindFloat(i+1, a)
+		case string:
+			err = stmt.BindText(i+1, a)
+		case []byte:
+			err = stmt.BindBlob(i+1, a)
+		case time.Time:
+			err = stmt.BindTime(i+1, a, sqlite3.TimeFormatDefault)
+		case nil:
+			err = stmt.BindNull(i + 1)
+		default:
+			err = fmt.Errorf("livequery: unsupported argument type %T", arg)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
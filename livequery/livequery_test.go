@@ -0,0 +1,104 @@
+package livequery_test
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ncruces/go-sqlite3"
+	"github.com/ncruces/go-sqlite3/changefeed"
+	_ "github.com/ncruces/go-sqlite3/embed"
+	"github.com/ncruces/go-sqlite3/livequery"
+)
+
+func TestWatch(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "test.db")
+	db, err := sqlite3.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	err = db.Exec(`
+		CREATE TABLE t (x, y);
+		CREATE TABLE u (x);
+		INSERT INTO t VALUES (1, 'a'), (2, 'b');
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = changefeed.Attach(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer changefeed.Detach(db)
+
+	conn, err := sqlite3.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	q, err := livequery.Watch(ctx, conn, `SELECT x FROM t WHERE x > ? ORDER BY x`, []any{1},
+		&livequery.Options{Debounce: time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string][]string{"t": {"x"}}; !reflect.DeepEqual(q.Reads, want) {
+		t.Errorf("got %v, want %v", q.Reads, want)
+	}
+
+	next := func() [][]any {
+		t.Helper()
+		select {
+		case res := <-q.C:
+			return res.Rows
+		case <-time.After(5 * time.Second):
+			t.Fatal("timeout")
+			return nil
+		}
+	}
+
+	if got, want := next(), [][]any{{int64(2)}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	// These don't change the result.
+	err = db.Exec(`INSERT INTO u VALUES (1)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.Exec(`UPDATE t SET x = x`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = db.Exec(`INSERT INTO t VALUES (3, 'c')`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := next(), [][]any{{int64(2)}, {int64(3)}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	select {
+	case res := <-q.C:
+		t.Errorf("got %v, want nothing", res)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	cancel()
+	for range q.C {
+	}
+	if err := q.Err(); err != context.Canceled {
+		t.Errorf("got %v, want context.Canceled", err)
+	}
+}
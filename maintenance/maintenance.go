@@ -0,0 +1,313 @@
+// Package maintenance schedules periodic database maintenance.
+//
+// A [Scheduler] runs PRAGMA optimize, incremental vacuums,
+// WAL checkpoints and ANALYZE on a dedicated connection,
+// at quiet moments, when they are due.
+// Connections that write to the database report
+// the size of the WAL and freelist to the scheduler,
+// through hooks installed by [Scheduler.Hook].
+package maintenance
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/ncruces/go-sqlite3"
+)
+
+// Task is a maintenance task.
+type Task uint8
+
+const (
+	OPTIMIZE Task = iota + 1
+	INCREMENTAL_VACUUM
+	CHECKPOINT
+	ANALYZE
+)
+
+// String implements [fmt.Stringer].
+func (t Task) String() string {
+	switch t {
+	case OPTIMIZE:
+		return "optimize"
+	case INCREMENTAL_VACUUM:
+		return "incremental_vacuum"
+	case CHECKPOINT:
+		return "checkpoint"
+	case ANALYZE:
+		return "analyze"
+	}
+	return fmt.Sprintf("Task(%d)", uint8(t))
+}
+
+// Result reports a task that was run.
+type Result struct {
+	Task     Task
+	Start    time.Time
+	Duration time.Duration
+	// Pages is the number of pages checkpointed or vacuumed.
+	Pages int
+	Err   error
+}
+
+// Options configure a [Scheduler].
+type Options struct {
+	// Schema is the name of the database to maintain.
+	// If empty, "main" is used.
+	Schema string
+
+	// Quiet is how long after the last write tasks wait to run.
+	// If zero, 1 second is used.
+	Quiet time.Duration
+
+	// OptimizeInterval is the time between runs of PRAGMA optimize.
+	// If zero, 1 hour is used. If negative, optimize doesn't run.
+	OptimizeInterval time.Duration
+	// AnalyzeInterval is the time between runs of ANALYZE.
+	// If zero or negative, ANALYZE doesn't run.
+	AnalyzeInterval time.Duration
+
+	// CheckpointPages is the size of the WAL, in pages,
+	// that triggers a TRUNCATE checkpoint.
+	// If zero, 1000 pages are used.
+	CheckpointPages int
+	// CheckpointInterval is the maximum time between checkpoints
+	// of a WAL that is not empty.
+	// If zero or negative, checkpoints are triggered only by size.
+	CheckpointInterval time.Duration
+
+	// VacuumPages is the number of free pages
+	// that triggers a vacuum.
+	// If zero, 1000 pages are used.
+	VacuumPages int
+
+	// Report, if not nil, is called after each task.
+	Report func(Result)
+}
+
+// Scheduler schedules maintenance tasks.
+type Scheduler struct {
+	conn *sqlite3.Conn
+	opts Options
+
+	notify    chan struct{}
+	lastWrite atomic.Int64
+	walPages  atomic.Int64
+
+	lastOptimize   time.Time
+	lastAnalyze    time.Time
+	lastCheckpoint time.Time
+}
+
+// New creates a scheduler that runs tasks on c.
+// The connection must not be used for anything else.
+func New(c *sqlite3.Conn, opts *Options) *Scheduler {
+	s := &Scheduler{conn: c, notify: make(chan struct{}, 1)}
+	if opts != nil {
+		s.opts = *opts
+	}
+	if s.opts.Schema == "" {
+		s.opts.Schema = "main"
+	}
+	if s.opts.Quiet == 0 {
+		s.opts.Quiet = time.Second
+	}
+	if s.opts.OptimizeInterval == 0 {
+		s.opts.OptimizeInterval = time.Hour
+	}
+	if s.opts.CheckpointPages == 0 {
+		s.opts.CheckpointPages = 1000
+	}
+	if s.opts.VacuumPages == 0 {
+		s.opts.VacuumPages = 1000
+	}
+
+	now := time.Now()
+	s.lastOptimize = now
+	s.lastAnalyze = now
+	s.lastCheckpoint = now
+	return s
+}
+
+// Hook installs a WAL hook and an autovacuum pages callback on c,
+// a connection that writes to the database.
+//
+// The WAL hook reports the size of the WAL to the scheduler.
+// The autovacuum pages callback defers compaction of
+// databases with auto_vacuum=FULL until there are VacuumPages free pages.
+// Databases with auto_vacuum=INCREMENTAL are vacuumed by the scheduler.
+//
+// Hook replaces the WAL hook of c, and the hook disables automatic checkpoints.
+// A connection can't be hooked by both Hook and
+// [github.com/ncruces/go-sqlite3/replicate.Attach],
+// which also checkpoints the database.
+func (s *Scheduler) Hook(c *sqlite3.Conn) error {
+	err := c.AutoVacuumPages(func(schema string, dbPages, freePages, bytesPerPage uint) uint {
+		if schema != s.opts.Schema || freePages >= uint(s.opts.VacuumPages) {
+			return freePages
+		}
+		return 0
+	})
+	if err != nil {
+		return err
+	}
+	err = c.WalAutoCheckpoint(0)
+	if err != nil {
+		return err
+	}
+	c.WalHook(func(_ *sqlite3.Conn, schema string, pages int) error {
+		if schema == s.opts.Schema {
+			s.lastWrite.Store(time.Now().UnixNano())
+			s.walPages.Store(int64(pages))
+			if pages >= s.opts.CheckpointPages {
+				s.wake()
+			}
+		}
+		return nil
+	})
+	return nil
+}
+
+func (s *Scheduler) wake() {
+	select {
+	case s.notify <- struct{}{}:
+	default:
+	}
+}
+
+// Run runs tasks as they become due, until ctx is done.
+// It always returns a non-nil error: ctx.Err(),
+// or an error that prevented scheduling tasks.
+//
+// Errors from tasks themselves are reported
+// through Options.Report, and don't stop Run.
+func (s *Scheduler) Run(ctx context.Context) error {
+	old := s.conn.SetInterrupt(ctx)
+	defer s.conn.SetInterrupt(old)
+
+	timer := time.NewTimer(s.opts.Quiet)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-s.notify:
+			if !timer.Stop() {
+				<-timer.C
+			}
+		case <-timer.C:
+		}
+
+		wait := s.opts.Quiet
+		if quiet := time.Since(time.Unix(0, s.lastWrite.Load())); quiet < s.opts.Quiet {
+			wait -= quiet
+		} else if _, err := s.RunDue(ctx); err != nil {
+			return err
+		}
+		timer.Reset(wait)
+	}
+}
+
+// RunDue runs the tasks that are due now, and returns what was done.
+// It returns an error only if it fails to check which tasks are due,
+// or if ctx is done.
+func (s *Scheduler) RunDue(ctx context.Context) ([]Result, error) {
+	var res []Result
+	run := func(task Task, fn func() (int, error)) {
+		r := Result{Task: task, Start: time.Now()}
+		r.Pages, r.Err = fn()
+		r.Duration = time.Since(r.Start)
+		if s.opts.Report != nil {
+			s.opts.Report(r)
+		}
+		res = append(res, r)
+	}
+
+	now := time.Now()
+	if s.walPages.Load() >= int64(s.opts.CheckpointPages) ||
+		s.opts.CheckpointInterval > 0 && s.walPages.Load() > 0 &&
+			now.Sub(s.lastCheckpoint) >= s.opts.CheckpointInterval {
+		run(CHECKPOINT, s.checkpoint)
+	}
+	if ctx.Err() != nil {
+		return res, ctx.Err()
+	}
+
+	vacuum, err := s.vacuumDue()
+	if err != nil {
+		return res, err
+	}
+	if vacuum {
+		run(INCREMENTAL_VACUUM, s.incrementalVacuum)
+	}
+	if ctx.Err() != nil {
+		return res, ctx.Err()
+	}
+
+	if s.opts.AnalyzeInterval > 0 && now.Sub(s.lastAnalyze) >= s.opts.AnalyzeInterval {
+		s.lastAnalyze = now
+		s.lastOptimize = now
+		run(ANALYZE, func() (int, error) {
+			return 0, s.conn.Exec(`ANALYZE ` + sqlite3.QuoteIdentifier(s.opts.Schema))
+		})
+	} else if s.opts.OptimizeInterval > 0 && now.Sub(s.lastOptimize) >= s.opts.OptimizeInterval {
+		s.lastOptimize = now
+		run(OPTIMIZE, func() (int, error) {
+			return 0, s.conn.Exec(`PRAGMA ` + sqlite3.QuoteIdentifier(s.opts.Schema) + `.optimize`)
+		})
+	}
+	return res, ctx.Err()
+}
+
+func (s *Scheduler) checkpoint() (int, error) {
+	pages := s.walPages.Load()
+	nLog, nCkpt, err := s.conn.WalCheckpoint(s.opts.Schema, sqlite3.CHECKPOINT_TRUNCATE)
+	if err != nil || nLog != nCkpt {
+		return max(0, nCkpt), err
+	}
+	// The WAL was truncated, and both counts are zero.
+	s.walPages.Store(0)
+	s.lastCheckpoint = time.Now()
+	return int(pages), nil
+}
+
+func (s *Scheduler) vacuumDue() (bool, error) {
+	mode, err := s.pragma("auto_vacuum")
+	if err != nil || mode != 2 {
+		return false, err
+	}
+	free, err := s.pragma("freelist_count")
+	if err != nil {
+		return false, err
+	}
+	return free >= int64(s.opts.VacuumPages), nil
+}
+
+func (s *Scheduler) incrementalVacuum() (int, error) {
+	before, err := s.pragma("freelist_count")
+	if err != nil {
+		return 0, err
+	}
+	err = s.conn.Exec(`PRAGMA ` + sqlite3.QuoteIdentifier(s.opts.Schema) + `.incremental_vacuum`)
+	if err != nil {
+		return 0, err
+	}
+	after, err := s.pragma("freelist_count")
+	return int(before - after), err
+}
+
+func (s *Scheduler) pragma(name string) (int64, error) {
+	stmt, _, err := s.conn.Prepare(`PRAGMA ` + sqlite3.QuoteIdentifier(s.opts.Schema) + `.` + name)
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+
+	if !stmt.Step() {
+		return 0, stmt.Err()
+	}
+	return stmt.ColumnInt64(0), stmt.Close()
+}
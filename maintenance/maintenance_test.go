@@ -0,0 +1,112 @@
+package maintenance_test
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ncruces/go-sqlite3"
+	_ "github.com/ncruces/go-sqlite3/embed"
+	"github.com/ncruces/go-sqlite3/maintenance"
+)
+
+func TestScheduler(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "test.db")
+	db, err := sqlite3.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	err = db.Exec(`
+		PRAGMA auto_vacuum=INCREMENTAL;
+		PRAGMA journal_mode=WAL;
+		CREATE TABLE t (x);
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conn, err := sqlite3.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	var mtx sync.Mutex
+	var results []maintenance.Result
+	s := maintenance.New(conn, &maintenance.Options{
+		Quiet:           10 * time.Millisecond,
+		CheckpointPages: 50,
+		VacuumPages:     20,
+		Report: func(r maintenance.Result) {
+			mtx.Lock()
+			defer mtx.Unlock()
+			results = append(results, r)
+		},
+	})
+
+	err = s.Hook(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 100; i++ {
+		err = db.Exec(`INSERT INTO t VALUES (randomblob(2000))`)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = db.Exec(`DELETE FROM t`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	done := make(chan error)
+	go func() { done <- s.Run(ctx) }()
+
+	for ctx.Err() == nil {
+		mtx.Lock()
+		n := len(results)
+		mtx.Unlock()
+		if n >= 2 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Errorf("got %v, want context.Canceled", err)
+	}
+
+	mtx.Lock()
+	defer mtx.Unlock()
+	if len(results) != 2 {
+		t.Fatalf("got %v", results)
+	}
+	for i, task := range []maintenance.Task{maintenance.CHECKPOINT, maintenance.INCREMENTAL_VACUUM} {
+		r := results[i]
+		if r.Task != task || r.Err != nil || r.Pages == 0 {
+			t.Errorf("got %+v, want %v", r, task)
+		}
+	}
+
+	stmt, _, err := db.Prepare(`PRAGMA freelist_count`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stmt.Close()
+	if !stmt.Step() {
+		t.Fatal(stmt.Err())
+	}
+	if got := stmt.ColumnInt(0); got != 0 {
+		t.Errorf("got %d free pages, want 0", got)
+	}
+}
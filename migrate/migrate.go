@@ -0,0 +1,285 @@
+// Package migrate applies schema migrations to SQLite databases.
+//
+// Migrations are applied in order of version,
+// each in its own "immediate" transaction.
+// Applied migrations are tracked in PRAGMA [user_version],
+// or in a table, if configured.
+//
+// [user_version]: https://sqlite.org/pragma.html#pragma_user_version
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ncruces/go-sqlite3"
+	"github.com/ncruces/go-sqlite3/internal/util"
+)
+
+// Migration is a schema migration step.
+type Migration struct {
+	// Version must be positive, and unique.
+	Version int
+	Name    string
+	// SQL is executed first, if not empty.
+	SQL string
+	// Func is called next, if not nil.
+	Func func(*sqlite3.Conn) error
+	// DisableForeignKeys disables foreign key constraints
+	// while the migration is applied,
+	// and checks foreign keys before committing.
+	// Use it to change a table's schema with the 12-step
+	// [generalized ALTER TABLE procedure].
+	//
+	// [generalized ALTER TABLE procedure]: https://sqlite.org/lang_altertable.html#otheralter
+	DisableForeignKeys bool
+}
+
+// Migrator applies migrations to a database.
+type Migrator struct {
+	// Migrations to apply, in any order.
+	Migrations []Migration
+	// Table is the name of the table used to track applied migrations.
+	// If empty, PRAGMA user_version is used.
+	Table string
+}
+
+// Load loads SQL migrations from the files in fsys
+// matching the pattern "*.sql".
+//
+// File names must start with the version number, optionally followed
+// by an underscore and the name of the migration: e.g. "0001_init.sql".
+func Load(fsys fs.FS) ([]Migration, error) {
+	files, err := fs.Glob(fsys, "*.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	var res []Migration
+	for _, file := range files {
+		base := strings.TrimSuffix(path.Base(file), ".sql")
+		num, name, _ := strings.Cut(base, "_")
+		version, err := strconv.Atoi(num)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("migrate: invalid migration file name: %s", file)
+		}
+		data, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, Migration{
+			Version: version,
+			Name:    name,
+			SQL:     string(data),
+		})
+	}
+	return res, nil
+}
+
+// Migrate applies pending migrations to c.
+func (m *Migrator) Migrate(c *sqlite3.Conn) error {
+	migrations, err := m.sorted()
+	if err != nil {
+		return err
+	}
+	if err := m.init(c); err != nil {
+		return err
+	}
+	for _, mig := range migrations {
+		if err := m.apply(c, mig); err != nil {
+			return fmt.Errorf("migrate: version %d: %w", mig.Version, err)
+		}
+	}
+	return nil
+}
+
+// MigrateDB applies pending migrations to db.
+// A single connection from db is used.
+func (m *Migrator) MigrateDB(ctx context.Context, db *sql.DB) error {
+	return withConn(ctx, db, m.Migrate)
+}
+
+// Version returns the version of the last applied migration.
+func (m *Migrator) Version(c *sqlite3.Conn) (int, error) {
+	if err := m.init(c); err != nil {
+		return 0, err
+	}
+	return m.version(c)
+}
+
+// VersionDB returns the version of the last applied migration.
+func (m *Migrator) VersionDB(ctx context.Context, db *sql.DB) (version int, err error) {
+	err = withConn(ctx, db, func(c *sqlite3.Conn) error {
+		version, err = m.Version(c)
+		return err
+	})
+	return version, err
+}
+
+func (m *Migrator) sorted() ([]Migration, error) {
+	res := append([]Migration(nil), m.Migrations...)
+	sort.SliceStable(res, func(i, j int) bool {
+		return res[i].Version < res[j].Version
+	})
+	for i, mig := range res {
+		if mig.Version <= 0 {
+			return nil, fmt.Errorf("migrate: invalid version: %d", mig.Version)
+		}
+		if i > 0 && res[i-1].Version == mig.Version {
+			return nil, fmt.Errorf("migrate: duplicate version: %d", mig.Version)
+		}
+	}
+	return res, nil
+}
+
+func (m *Migrator) init(c *sqlite3.Conn) error {
+	if m.Table == "" {
+		return nil
+	}
+	return c.Exec(`CREATE TABLE IF NOT EXISTS ` + sqlite3.QuoteIdentifier(m.Table) + ` (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)`)
+}
+
+func (m *Migrator) version(c *sqlite3.Conn) (int, error) {
+	query := `PRAGMA user_version`
+	if m.Table != "" {
+		query = `SELECT coalesce(max(version), 0) FROM ` + sqlite3.QuoteIdentifier(m.Table)
+	}
+
+	stmt, _, err := c.Prepare(query)
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+
+	var version int
+	if stmt.Step() {
+		version = stmt.ColumnInt(0)
+	}
+	return version, stmt.Err()
+}
+
+func (m *Migrator) setVersion(c *sqlite3.Conn, mig Migration) error {
+	if m.Table == "" {
+		return c.Exec(`PRAGMA user_version=` + strconv.Itoa(mig.Version))
+	}
+
+	stmt, _, err := c.Prepare(`INSERT INTO ` + sqlite3.QuoteIdentifier(m.Table) + ` (version, name) VALUES (?, ?)`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	stmt.BindInt(1, mig.Version)
+	stmt.BindText(2, mig.Name)
+	return stmt.Exec()
+}
+
+func (m *Migrator) apply(c *sqlite3.Conn, mig Migration) (err error) {
+	if v, err := m.version(c); err != nil || v >= mig.Version {
+		return err
+	}
+
+	// Foreign keys can't be disabled inside a transaction.
+	var fks bool
+	if mig.DisableForeignKeys {
+		fks, err = foreignKeys(c)
+		if err != nil {
+			return err
+		}
+		if fks {
+			if err := c.Exec(`PRAGMA foreign_keys=0`); err != nil {
+				return err
+			}
+			defer func() {
+				if ferr := c.Exec(`PRAGMA foreign_keys=1`); err == nil {
+					err = ferr
+				}
+			}()
+		}
+	}
+
+	tx, err := c.BeginImmediate()
+	if err != nil {
+		return err
+	}
+	defer tx.End(&err)
+
+	// Recheck the version, another connection may have applied it.
+	if v, err := m.version(c); err != nil || v >= mig.Version {
+		return err
+	}
+
+	if mig.SQL != "" {
+		if err := c.Exec(mig.SQL); err != nil {
+			return err
+		}
+	}
+	if mig.Func != nil {
+		if err := mig.Func(c); err != nil {
+			return err
+		}
+	}
+	if fks {
+		if err := foreignKeyCheck(c); err != nil {
+			return err
+		}
+	}
+	return m.setVersion(c, mig)
+}
+
+func foreignKeys(c *sqlite3.Conn) (bool, error) {
+	stmt, _, err := c.Prepare(`PRAGMA foreign_keys`)
+	if err != nil {
+		return false, err
+	}
+	defer stmt.Close()
+
+	var fks bool
+	if stmt.Step() {
+		fks = stmt.ColumnBool(0)
+	}
+	return fks, stmt.Err()
+}
+
+func foreignKeyCheck(c *sqlite3.Conn) error {
+	stmt, _, err := c.Prepare(`PRAGMA foreign_key_check`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	if stmt.Step() {
+		return fmt.Errorf("migrate: foreign key violation: table %s, rowid %d, references %s",
+			stmt.ColumnText(0), stmt.ColumnInt64(1), stmt.ColumnText(2))
+	}
+	return stmt.Err()
+}
+
+func withConn(ctx context.Context, db *sql.DB, fn func(*sqlite3.Conn) error) error {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	return conn.Raw(func(driverConn any) error {
+		dc, ok := driverConn.(sqlite3.DriverConn)
+		if !ok {
+			return util.ErrorString("migrate: not an SQLite connection")
+		}
+		c := dc.Raw()
+		old := c.SetInterrupt(ctx)
+		defer c.SetInterrupt(old)
+		return fn(c)
+	})
+}
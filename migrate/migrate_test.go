@@ -0,0 +1,164 @@
+package migrate_test
+
+import (
+	"context"
+	"testing"
+	"testing/fstest"
+
+	"github.com/ncruces/go-sqlite3"
+	"github.com/ncruces/go-sqlite3/driver"
+	_ "github.com/ncruces/go-sqlite3/embed"
+	"github.com/ncruces/go-sqlite3/migrate"
+	_ "github.com/ncruces/go-sqlite3/vfs/memdb"
+)
+
+var files = fstest.MapFS{
+	"0001_users.sql": {Data: []byte(`
+		CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT);
+		CREATE TABLE posts (id INTEGER PRIMARY KEY, user_id REFERENCES users);
+	`)},
+	"0002_seed.sql": {Data: []byte(`
+		INSERT INTO users VALUES (1, 'alice');
+		INSERT INTO posts VALUES (1, 1);
+	`)},
+	"README.md": {Data: []byte(`ignored`)},
+}
+
+func TestMigrator_Migrate(t *testing.T) {
+	t.Parallel()
+
+	db, err := sqlite3.Open(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	err = db.Exec(`PRAGMA foreign_keys=1`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	migrations, err := migrate.Load(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(migrations) != 2 || migrations[0].Name != "users" {
+		t.Fatalf("got %+v", migrations)
+	}
+
+	m := migrate.Migrator{Migrations: migrations}
+	err = m.Migrate(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The 12-step generalized ALTER TABLE procedure.
+	m.Migrations = append(m.Migrations, migrate.Migration{
+		Version:            3,
+		Name:               "recreate",
+		DisableForeignKeys: true,
+		SQL: `
+			CREATE TABLE new_users (id INTEGER PRIMARY KEY, name TEXT NOT NULL);
+			INSERT INTO new_users SELECT id, name FROM users;
+			DROP TABLE users;
+			ALTER TABLE new_users RENAME TO users;
+		`,
+	})
+	err = m.Migrate(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	version, err := m.Version(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != 3 {
+		t.Errorf("got %d, want 3", version)
+	}
+
+	// Foreign keys are enabled again.
+	err = db.Exec(`INSERT INTO posts VALUES (2, 2)`)
+	if err == nil {
+		t.Error("want error")
+	}
+
+	// Failed migrations are rolled back.
+	m.Migrations = append(m.Migrations, migrate.Migration{
+		Version:            4,
+		DisableForeignKeys: true,
+		Func: func(c *sqlite3.Conn) error {
+			return c.Exec(`DELETE FROM users`)
+		},
+	})
+	err = m.Migrate(db)
+	if err == nil {
+		t.Error("want error")
+	}
+
+	version, err = m.Version(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != 3 {
+		t.Errorf("got %d, want 3", version)
+	}
+
+	m.Migrations = append(m.Migrations, migrate.Migration{Version: 4})
+	err = m.Migrate(db)
+	if err == nil {
+		t.Error("want error")
+	}
+}
+
+func TestMigrator_MigrateDB(t *testing.T) {
+	t.Parallel()
+
+	db, err := driver.Open("file:/migrate.db?vfs=memdb", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	migrations, err := migrate.Load(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	m := migrate.Migrator{Migrations: migrations, Table: "migrations"}
+	err = m.MigrateDB(ctx, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = m.MigrateDB(ctx, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	version, err := m.VersionDB(ctx, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != 2 {
+		t.Errorf("got %d, want 2", version)
+	}
+
+	var count int
+	err = db.QueryRow(`SELECT count(*) FROM migrations`).Scan(&count)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 {
+		t.Errorf("got %d, want 2", count)
+	}
+
+	var user int
+	err = db.QueryRow(`PRAGMA user_version`).Scan(&user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user != 0 {
+		t.Errorf("got %d, want 0", user)
+	}
+}
@@ -0,0 +1,210 @@
+package sqlite3
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+
+	"github.com/ncruces/go-sqlite3/internal/util"
+)
+
+// PoolConfig configures a [Pool].
+type PoolConfig struct {
+	// Size is the maximum number of reader connections.
+	// If zero, a default of 10 is used.
+	Size int
+	// Flags used to open connections.
+	// If zero, [Open] flags are used.
+	Flags OpenFlag
+	// Init is called when the pool opens a new connection.
+	// Any error returned closes the connection and is returned by [Pool.Take].
+	Init func(*Conn) error
+	// Writer enables a dedicated writer connection, see [Pool.TakeWriter].
+	// If enabled, reader connections are "query_only".
+	Writer bool
+}
+
+// Pool is a pool of database connections.
+// A Pool is safe for concurrent use by multiple goroutines.
+//
+// Connections are opened as needed,
+// up to the configured size,
+// and are reused once returned to the pool with [Pool.Put].
+type Pool struct {
+	filename string
+	config   PoolConfig
+	readers  chan *Conn
+	writer   chan *Conn
+	wconn    atomic.Pointer[Conn]
+	done     chan struct{}
+	close    sync.Once
+}
+
+// OpenPool creates a pool of connections to the database
+// specified by the filename argument.
+//
+// No connections are opened until they're needed.
+func OpenPool(filename string, config PoolConfig) (*Pool, error) {
+	if config.Size == 0 {
+		config.Size = 10
+	}
+	if config.Size < 0 {
+		return nil, MISUSE
+	}
+	if config.Flags == 0 {
+		config.Flags = OPEN_READWRITE | OPEN_CREATE | OPEN_URI | OPEN_NOFOLLOW
+	}
+
+	p := &Pool{
+		filename: filename,
+		config:   config,
+		readers:  make(chan *Conn, config.Size),
+		done:     make(chan struct{}),
+	}
+	for i := 0; i < config.Size; i++ {
+		p.readers <- nil
+	}
+	if config.Writer {
+		p.writer = make(chan *Conn, 1)
+		p.writer <- nil
+	}
+	return p, nil
+}
+
+// Take takes a connection from the pool,
+// waiting until a connection is available, or ctx is done.
+//
+// Connections are interrupted when ctx is done: see [Conn.SetInterrupt].
+// The connection must be returned to the pool with [Pool.Put].
+func (p *Pool) Take(ctx context.Context) (*Conn, error) {
+	return p.take(ctx, p.readers, false)
+}
+
+// TakeWriter takes the dedicated writer connection from the pool,
+// waiting until it is available, or ctx is done.
+// If the pool has no dedicated writer, TakeWriter works like [Pool.Take].
+//
+// The connection is interrupted when ctx is done: see [Conn.SetInterrupt].
+// The connection must be returned to the pool with [Pool.Put].
+func (p *Pool) TakeWriter(ctx context.Context) (*Conn, error) {
+	if p.writer == nil {
+		return p.Take(ctx)
+	}
+	return p.take(ctx, p.writer, true)
+}
+
+func (p *Pool) take(ctx context.Context, slots chan *Conn, writer bool) (*Conn, error) {
+	select {
+	case <-p.done:
+		return nil, poolClosedErr
+	default:
+	}
+
+	var c *Conn
+	select {
+	case c = <-slots:
+	case <-p.done:
+		return nil, poolClosedErr
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	if c == nil {
+		var err error
+		c, err = p.open(writer)
+		if err != nil {
+			slots <- nil
+			return nil, err
+		}
+	}
+	c.SetInterrupt(ctx)
+	return c, nil
+}
+
+func (p *Pool) open(writer bool) (c *Conn, err error) {
+	c, err = OpenFlags(p.filename, p.config.Flags)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			c.Close()
+			c = nil
+		}
+	}()
+
+	if p.config.Init != nil {
+		err = p.config.Init(c)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if writer {
+		p.wconn.Store(c)
+	} else if p.config.Writer {
+		err = c.Exec(`PRAGMA query_only=1`)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// Put returns a connection to the pool.
+//
+// Any transaction left open is rolled back,
+// and the interrupt context is cleared.
+// If the transaction can't be rolled back, the connection is closed.
+//
+// It is safe to put a nil Conn.
+func (p *Pool) Put(c *Conn) {
+	if c == nil {
+		return
+	}
+
+	slots := p.readers
+	if c == p.wconn.Load() {
+		slots = p.writer
+	}
+
+	c.SetInterrupt(nil)
+	if !c.GetAutocommit() {
+		if err := c.txnExecInterrupted(`ROLLBACK`); err != nil {
+			c.Close()
+			c = nil
+		}
+	}
+	select {
+	case slots <- c:
+	default:
+		panic(util.ErrorString("sqlite3: connection not from pool"))
+	}
+}
+
+// Close closes all connections in the pool,
+// blocking until all connections are returned to the pool.
+//
+// Calling [Pool.Take] on a closed pool returns an error.
+// It is safe to close a closed Pool.
+func (p *Pool) Close() (err error) {
+	p.close.Do(func() { err = p.closeConns() })
+	return err
+}
+
+func (p *Pool) closeConns() error {
+	close(p.done)
+
+	var errs []error
+	for i := 0; i < cap(p.readers); i++ {
+		c := <-p.readers
+		errs = append(errs, c.Close())
+	}
+	if p.writer != nil {
+		c := <-p.writer
+		errs = append(errs, c.Close())
+	}
+	return errors.Join(errs...)
+}
+
+const poolClosedErr = util.ErrorString("sqlite3: pool closed")
@@ -0,0 +1,103 @@
+package queue
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/ncruces/go-sqlite3"
+)
+
+// DB is a [Queue] that uses a [database/sql] database
+// opened with the [github.com/ncruces/go-sqlite3/driver] package.
+//
+// Each method takes a connection from the pool,
+// and returns it before returning.
+// [DB.Receive] doesn't hold a connection while it waits.
+type DB struct {
+	Queue *Queue
+	DB    *sql.DB
+}
+
+func (d DB) raw(ctx context.Context, fn func(*sqlite3.Conn) error) error {
+	conn, err := d.DB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	return conn.Raw(func(driverConn any) error {
+		c, ok := driverConn.(interface{ Raw() *sqlite3.Conn })
+		if !ok {
+			return errors.New("queue: not an SQLite connection")
+		}
+		return fn(c.Raw())
+	})
+}
+
+// Init creates the tables of the queue, if they don't exist.
+func (d DB) Init(ctx context.Context) error {
+	return d.raw(ctx, d.Queue.Init)
+}
+
+// Enqueue adds a job to the queue, and returns its ID.
+//
+// To enqueue a job in a transaction, use [Queue.Enqueue]
+// with the connection of the transaction, see [sql.Conn.Raw].
+func (d DB) Enqueue(ctx context.Context, payload []byte, opts *EnqueueOptions) (id int64, err error) {
+	err = d.raw(ctx, func(c *sqlite3.Conn) error {
+		id, err = d.Queue.Enqueue(c, payload, opts)
+		return err
+	})
+	return id, err
+}
+
+// Dequeue leases the next available job.
+// If no job is available, it returns nil.
+func (d DB) Dequeue(ctx context.Context) (job *Job, err error) {
+	err = d.raw(ctx, func(c *sqlite3.Conn) error {
+		job, err = d.Queue.Dequeue(c)
+		return err
+	})
+	return job, err
+}
+
+// Receive leases the next available job,
+// blocking until one is available, or ctx is done.
+func (d DB) Receive(ctx context.Context) (*Job, error) {
+	var filename string
+	err := d.raw(ctx, func(c *sqlite3.Conn) error {
+		name := c.Filename("main")
+		if name == nil {
+			return errors.New("queue: database has no filename")
+		}
+		filename = name.String()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return d.Queue.receive(ctx, filename, true, func() (job *Job, next time.Time, err error) {
+		err = d.raw(ctx, func(c *sqlite3.Conn) error {
+			job, next, err = d.Queue.dequeue(c)
+			return err
+		})
+		return job, next, err
+	})
+}
+
+// Ack acknowledges that job was processed, and removes it from the queue.
+func (d DB) Ack(ctx context.Context, job *Job) error {
+	return d.raw(ctx, func(c *sqlite3.Conn) error {
+		return d.Queue.Ack(c, job)
+	})
+}
+
+// Nack reports that processing job failed, and releases it.
+func (d DB) Nack(ctx context.Context, job *Job, reason error) error {
+	return d.raw(ctx, func(c *sqlite3.Conn) error {
+		return d.Queue.Nack(c, job, reason)
+	})
+}
@@ -0,0 +1,442 @@
+// Package queue implements a durable job queue on top of SQLite.
+//
+// Jobs are enqueued with a priority and an optional delay.
+// Dequeued jobs are leased for a visibility timeout:
+// unless they're acknowledged with [Queue.Ack] before the lease expires,
+// they're delivered again.
+// Jobs that fail too many times are moved to a dead-letter table.
+//
+// [Queue.Receive] blocks until a job is available.
+// Receivers are woken when jobs are enqueued
+// or released by connections in the same process.
+//
+// [Queue] works with [sqlite3.Conn] connections;
+// [DB] works with a [database/sql] database opened with the
+// [github.com/ncruces/go-sqlite3/driver] package.
+package queue
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/ncruces/go-sqlite3"
+)
+
+// ErrLeaseLost is returned when acknowledging a job
+// whose lease expired, and that was delivered again.
+var ErrLeaseLost = errors.New("queue: lease lost")
+
+// Options configure a [Queue].
+type Options struct {
+	// Table is the name of the table that stores jobs.
+	// Dead jobs are stored in a table with the same name, suffixed "_dead".
+	// If empty, "queue" is used.
+	Table string
+	// VisibilityTimeout is how long dequeued jobs are leased for.
+	// If zero, 30 seconds are used.
+	VisibilityTimeout time.Duration
+	// MaxAttempts is how many times a job is delivered
+	// before it's moved to the dead-letter table.
+	// If zero, 5 attempts are used.
+	MaxAttempts int
+	// RetryDelay is how long a job that is nacked waits before its first retry.
+	// The delay doubles with each attempt.
+	// If zero, jobs are retried immediately.
+	RetryDelay time.Duration
+}
+
+// EnqueueOptions configure a job.
+type EnqueueOptions struct {
+	// Priority orders jobs: jobs with higher priorities are dequeued first.
+	Priority int
+	// Delay postpones delivery of the job.
+	Delay time.Duration
+}
+
+// Job is a dequeued job.
+type Job struct {
+	ID       int64
+	Payload  []byte
+	Priority int
+	// Attempts is the number of times the job was delivered,
+	// including this one.
+	Attempts int
+	// Deadline is when the lease expires.
+	Deadline time.Time
+
+	lease int64
+}
+
+// Queue is a named queue of jobs.
+// Multiple queues can share the same tables.
+// A Queue is safe for concurrent use by multiple goroutines,
+// but each connection should be used by one goroutine at a time.
+type Queue struct {
+	name string
+	opts Options
+	sql  statements
+}
+
+// New creates a queue.
+func New(name string, opts *Options) *Queue {
+	q := &Queue{name: name}
+	if opts != nil {
+		q.opts = *opts
+	}
+	if q.opts.Table == "" {
+		q.opts.Table = "queue"
+	}
+	if q.opts.VisibilityTimeout == 0 {
+		q.opts.VisibilityTimeout = 30 * time.Second
+	}
+	if q.opts.MaxAttempts == 0 {
+		q.opts.MaxAttempts = 5
+	}
+	q.sql = newStatements(q.opts.Table)
+	return q
+}
+
+// Init creates the tables of the queue, if they don't exist.
+func (q *Queue) Init(c *sqlite3.Conn) error {
+	return c.Exec(q.sql.create)
+}
+
+// Enqueue adds a job to the queue, and returns its ID.
+//
+// If c is in a transaction, the job is enqueued when the transaction commits.
+// Receivers are woken when Enqueue returns;
+// while the transaction holds the database, they wait and retry.
+func (q *Queue) Enqueue(c *sqlite3.Conn, payload []byte, opts *EnqueueOptions) (int64, error) {
+	id, err := q.enqueue(c, payload, opts)
+	if err == nil {
+		q.notify(c)
+	}
+	return id, err
+}
+
+func (q *Queue) enqueue(c *sqlite3.Conn, payload []byte, opts *EnqueueOptions) (id int64, err error) {
+	var o EnqueueOptions
+	if opts != nil {
+		o = *opts
+	}
+
+	savept := c.Savepoint()
+	defer savept.Release(&err)
+
+	stmt, _, err := c.Prepare(q.sql.enqueue)
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+
+	now := time.Now()
+	stmt.BindText(1, q.name)
+	stmt.BindBlob(2, payload)
+	stmt.BindInt(3, o.Priority)
+	stmt.BindInt64(4, now.Add(o.Delay).UnixMilli())
+	stmt.BindInt64(5, now.UnixMilli())
+	if err := stmt.Exec(); err != nil {
+		return 0, err
+	}
+	return c.LastInsertRowID(), nil
+}
+
+// Dequeue leases the next available job.
+// If no job is available, it returns nil.
+//
+// Jobs that expired after MaxAttempts deliveries are moved to the dead-letter table.
+//
+// If c is not in a transaction, Dequeue uses an immediate transaction.
+func (q *Queue) Dequeue(c *sqlite3.Conn) (job *Job, err error) {
+	job, _, err = q.dequeue(c)
+	return job, err
+}
+
+// dequeue also returns when the next job becomes available.
+func (q *Queue) dequeue(c *sqlite3.Conn) (job *Job, next time.Time, err error) {
+	if c.GetAutocommit() {
+		var tx sqlite3.Txn
+		tx, err = c.BeginImmediate()
+		if err != nil {
+			return nil, time.Time{}, err
+		}
+		defer tx.End(&err)
+	} else {
+		savept := c.Savepoint()
+		defer savept.Release(&err)
+	}
+
+	now := time.Now()
+	for {
+		stmt, _, err := c.Prepare(q.sql.next)
+		if err != nil {
+			return nil, time.Time{}, err
+		}
+		stmt.BindText(1, q.name)
+		stmt.BindInt64(2, now.UnixMilli())
+
+		if !stmt.Step() {
+			err := stmt.Close()
+			if err != nil {
+				return nil, time.Time{}, err
+			}
+			next, err := q.nextVisible(c)
+			return nil, next, err
+		}
+		job := Job{
+			ID:       stmt.ColumnInt64(0),
+			Payload:  stmt.ColumnBlob(1, nil),
+			Priority: stmt.ColumnInt(2),
+			Attempts: stmt.ColumnInt(3) + 1,
+			Deadline: now.Add(q.opts.VisibilityTimeout),
+			lease:    rand.Int63(),
+		}
+		expired := !stmt.ColumnBool(4)
+		if err := stmt.Close(); err != nil {
+			return nil, time.Time{}, err
+		}
+
+		if expired && job.Attempts > q.opts.MaxAttempts {
+			err := q.bury(c, job.ID, "lease expired")
+			if err != nil {
+				return nil, time.Time{}, err
+			}
+			continue
+		}
+
+		stmt, _, err = c.Prepare(q.sql.lease)
+		if err != nil {
+			return nil, time.Time{}, err
+		}
+		defer stmt.Close()
+		stmt.BindInt64(1, job.Deadline.UnixMilli())
+		stmt.BindInt64(2, job.lease)
+		stmt.BindInt64(3, job.ID)
+		if err := stmt.Exec(); err != nil {
+			return nil, time.Time{}, err
+		}
+		return &job, time.Time{}, nil
+	}
+}
+
+func (q *Queue) nextVisible(c *sqlite3.Conn) (time.Time, error) {
+	stmt, _, err := c.Prepare(q.sql.nextVisible)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer stmt.Close()
+	stmt.BindText(1, q.name)
+
+	if !stmt.Step() || stmt.ColumnType(0) == sqlite3.NULL {
+		return time.Time{}, stmt.Err()
+	}
+	return time.UnixMilli(stmt.ColumnInt64(0)), nil
+}
+
+// Ack acknowledges that job was processed, and removes it from the queue.
+// If the lease of the job expired and it was delivered again,
+// Ack returns [ErrLeaseLost].
+func (q *Queue) Ack(c *sqlite3.Conn, job *Job) error {
+	stmt, _, err := c.Prepare(q.sql.ack)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	stmt.BindInt64(1, job.ID)
+	stmt.BindInt64(2, job.lease)
+	if err := stmt.Exec(); err != nil {
+		return err
+	}
+	if c.Changes() == 0 {
+		return ErrLeaseLost
+	}
+	return nil
+}
+
+// Nack reports that processing job failed, and releases it.
+// The job is retried after RetryDelay, doubled for each previous attempt,
+// or moved to the dead-letter table after MaxAttempts.
+// If the lease of the job expired and it was delivered again,
+// Nack returns [ErrLeaseLost].
+func (q *Queue) Nack(c *sqlite3.Conn, job *Job, reason error) error {
+	err := q.nack(c, job, reason)
+	if err == nil {
+		q.notify(c)
+	}
+	return err
+}
+
+func (q *Queue) nack(c *sqlite3.Conn, job *Job, reason error) (err error) {
+	savept := c.Savepoint()
+	defer savept.Release(&err)
+
+	var msg string
+	if reason != nil {
+		msg = reason.Error()
+	}
+
+	if job.Attempts >= q.opts.MaxAttempts {
+		stmt, _, err := c.Prepare(q.sql.leased)
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+		stmt.BindInt64(1, job.ID)
+		stmt.BindInt64(2, job.lease)
+		if !stmt.Step() {
+			if err := stmt.Err(); err != nil {
+				return err
+			}
+			return ErrLeaseLost
+		}
+		if err := stmt.Close(); err != nil {
+			return err
+		}
+		return q.bury(c, job.ID, msg)
+	}
+
+	delay := q.opts.RetryDelay << min(max(0, job.Attempts-1), 30)
+	stmt, _, err := c.Prepare(q.sql.release)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	stmt.BindInt64(1, time.Now().Add(delay).UnixMilli())
+	stmt.BindInt64(2, job.ID)
+	stmt.BindInt64(3, job.lease)
+	if err := stmt.Exec(); err != nil {
+		return err
+	}
+	if c.Changes() == 0 {
+		return ErrLeaseLost
+	}
+	return nil
+}
+
+// bury moves a job to the dead-letter table.
+func (q *Queue) bury(c *sqlite3.Conn, id int64, reason string) error {
+	stmt, _, err := c.Prepare(q.sql.bury)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	stmt.BindText(1, reason)
+	stmt.BindInt64(2, time.Now().UnixMilli())
+	stmt.BindInt64(3, id)
+	if err := stmt.Exec(); err != nil {
+		return err
+	}
+
+	stmt, _, err = c.Prepare(q.sql.delete)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	stmt.BindInt64(1, id)
+	return stmt.Exec()
+}
+
+// Receive leases the next available job,
+// blocking until one is available, or ctx is done.
+func (q *Queue) Receive(ctx context.Context, c *sqlite3.Conn) (*Job, error) {
+	name := c.Filename("main")
+	if name == nil {
+		return nil, errors.New("queue: database has no filename")
+	}
+	// In a transaction, waiting for a writer could deadlock.
+	retry := c.GetAutocommit()
+	return q.receive(ctx, name.String(), retry, func() (*Job, time.Time, error) {
+		return q.dequeue(c)
+	})
+}
+
+// receive retries dequeue while the database is busy (if retry is set),
+// since receivers are woken before the transactions that woke them commit.
+func (q *Queue) receive(ctx context.Context, filename string, retry bool, dequeue func() (*Job, time.Time, error)) (*Job, error) {
+	s := getSignal(filename, q.opts.Table, q.name)
+	delay := time.Millisecond
+	for {
+		// Get the channel before dequeuing, to not miss notifications.
+		wake := s.wait()
+
+		job, next, err := dequeue()
+		if retry && (errors.Is(err, sqlite3.BUSY) || errors.Is(err, sqlite3.LOCKED)) {
+			next, err = time.Now().Add(delay), nil
+			delay = min(2*delay, 100*time.Millisecond)
+		} else if job != nil || err != nil {
+			return job, err
+		}
+
+		var timer *time.Timer
+		var timeout <-chan time.Time
+		if !next.IsZero() {
+			timer = time.NewTimer(time.Until(next))
+			timeout = timer.C
+		}
+		select {
+		case <-ctx.Done():
+			err = ctx.Err()
+		case <-wake:
+		case <-timeout:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// notify wakes receivers, after jobs are enqueued or released.
+func (q *Queue) notify(c *sqlite3.Conn) {
+	name := c.Filename("main")
+	if name == nil {
+		return
+	}
+	getSignal(name.String(), q.opts.Table, q.name).broadcast()
+}
+
+type signalKey struct{ file, table, queue string }
+
+var (
+	signalsMtx sync.Mutex
+	signals    = map[signalKey]*signal{}
+)
+
+type signal struct {
+	mtx sync.Mutex
+	ch  chan struct{}
+}
+
+func getSignal(file, table, queue string) *signal {
+	signalsMtx.Lock()
+	defer signalsMtx.Unlock()
+	k := signalKey{file, table, queue}
+	s := signals[k]
+	if s == nil {
+		s = &signal{ch: make(chan struct{})}
+		signals[k] = s
+	}
+	return s
+}
+
+func (s *signal) wait() <-chan struct{} {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return s.ch
+}
+
+func (s *signal) broadcast() {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	close(s.ch)
+	s.ch = make(chan struct{})
+}
@@ -0,0 +1,358 @@
+package queue_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ncruces/go-sqlite3"
+	"github.com/ncruces/go-sqlite3/changefeed"
+	_ "github.com/ncruces/go-sqlite3/driver"
+	_ "github.com/ncruces/go-sqlite3/embed"
+	"github.com/ncruces/go-sqlite3/queue"
+)
+
+func open(t *testing.T, path string) *sqlite3.Conn {
+	t.Helper()
+	db, err := sqlite3.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	err = db.BusyTimeout(5 * time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestQueue(t *testing.T) {
+	t.Parallel()
+
+	db := open(t, filepath.Join(t.TempDir(), "test.db"))
+	q := queue.New("test", &queue.Options{
+		VisibilityTimeout: 50 * time.Millisecond,
+		MaxAttempts:       2,
+	})
+
+	err := q.Init(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	low, err := q.Enqueue(db, []byte("low"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	high, err := q.Enqueue(db, []byte("high"), &queue.EnqueueOptions{Priority: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = q.Enqueue(db, []byte("later"), &queue.EnqueueOptions{Priority: 2, Delay: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	job1, err := q.Dequeue(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job1 == nil || job1.ID != high || string(job1.Payload) != "high" {
+		t.Fatalf("got %+v, want high", job1)
+	}
+	job2, err := q.Dequeue(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job2 == nil || job2.ID != low || job2.Attempts != 1 {
+		t.Fatalf("got %+v, want low", job2)
+	}
+	job3, err := q.Dequeue(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job3 != nil {
+		t.Fatalf("got %+v, want nil", job3)
+	}
+
+	err = q.Ack(db, job1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = q.Ack(db, job1)
+	if !errors.Is(err, queue.ErrLeaseLost) {
+		t.Errorf("got %v, want ErrLeaseLost", err)
+	}
+
+	// The lease expires, and the job is delivered again.
+	time.Sleep(100 * time.Millisecond)
+	job3, err = q.Dequeue(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job3 == nil || job3.ID != low || job3.Attempts != 2 {
+		t.Fatalf("got %+v, want low", job3)
+	}
+	err = q.Ack(db, job2)
+	if !errors.Is(err, queue.ErrLeaseLost) {
+		t.Errorf("got %v, want ErrLeaseLost", err)
+	}
+
+	// After MaxAttempts, the job is dead.
+	err = q.Nack(db, job3, errors.New("failed"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	job3, err = q.Dequeue(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job3 != nil {
+		t.Fatalf("got %+v, want nil", job3)
+	}
+
+	stmt, _, err := db.Prepare(`SELECT id, attempts, error FROM queue_dead`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stmt.Close()
+	if !stmt.Step() {
+		t.Fatal(stmt.Err())
+	}
+	if id, attempts, msg := stmt.ColumnInt64(0), stmt.ColumnInt(1), stmt.ColumnText(2); id != low || attempts != 2 || msg != "failed" {
+		t.Errorf("got %d, %d, %q", id, attempts, msg)
+	}
+}
+
+func TestQueue_Receive(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "test.db")
+	db1 := open(t, path)
+	db2 := open(t, path)
+
+	q := queue.New("test", &queue.Options{RetryDelay: 50 * time.Millisecond})
+	err := q.Init(db1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	done := make(chan struct{})
+	defer func() { <-done }()
+	go func() {
+		defer close(done)
+		time.Sleep(50 * time.Millisecond)
+
+		tx, err := db1.BeginImmediate()
+		if err != nil {
+			panic(err)
+		}
+		defer tx.End(&err)
+		_, err = q.Enqueue(db1, []byte("job"), nil)
+	}()
+
+	job, err := q.Receive(ctx, db2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(job.Payload) != "job" {
+		t.Errorf("got %q", job.Payload)
+	}
+
+	// Retried after RetryDelay.
+	err = q.Nack(db2, job, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	job, err = q.Receive(ctx, db2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.Attempts != 2 {
+		t.Errorf("got %d attempts, want 2", job.Attempts)
+	}
+	if time.Since(start) < 40*time.Millisecond {
+		t.Error("retried too soon")
+	}
+	err = q.Ack(db2, job)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = q.Receive(ctx, db2)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v, want DeadlineExceeded", err)
+	}
+}
+
+func TestQueue_changefeed(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "test.db")
+	db1 := open(t, path)
+	db2 := open(t, path)
+
+	err := db1.Exec(`PRAGMA journal_mode=WAL`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := queue.New("test", nil)
+	err = q.Init(db1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = changefeed.Attach(db1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer changefeed.Detach(db1)
+	sub := changefeed.Subscribe(path, nil)
+	defer sub.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	done := make(chan struct{})
+	defer func() { <-done }()
+	go func() {
+		defer close(done)
+		time.Sleep(50 * time.Millisecond)
+
+		tx, err := db1.BeginImmediate()
+		if err != nil {
+			panic(err)
+		}
+		defer tx.End(&err)
+		_, err = q.Enqueue(db1, []byte("job"), nil)
+	}()
+
+	job, err := q.Receive(ctx, db2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(job.Payload) != "job" {
+		t.Errorf("got %q", job.Payload)
+	}
+
+	// The queue leaves the hooks of the changefeed in place.
+	select {
+	case changes := <-sub.C:
+		want := changefeed.Change{Table: "queue", RowID: job.ID, Action: sqlite3.AUTH_INSERT}
+		if len(changes) != 1 || changes[0] != want {
+			t.Errorf("got %v, want %v", changes, want)
+		}
+	case <-ctx.Done():
+		t.Fatal(ctx.Err())
+	}
+}
+
+func TestQueue_busy(t *testing.T) {
+	t.Parallel()
+
+	// Connections without a busy timeout.
+	path := filepath.Join(t.TempDir(), "test.db")
+	db1, err := sqlite3.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db1.Close()
+	db2, err := sqlite3.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db2.Close()
+
+	q := queue.New("test", nil)
+	err = q.Init(db1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	done := make(chan struct{})
+	defer func() { <-done }()
+	go func() {
+		defer close(done)
+		time.Sleep(50 * time.Millisecond)
+
+		tx, err := db1.BeginImmediate()
+		if err != nil {
+			panic(err)
+		}
+		defer tx.End(&err)
+		_, err = q.Enqueue(db1, []byte("job"), nil)
+		if err != nil {
+			return
+		}
+		// Receivers are woken before the transaction commits.
+		time.Sleep(50 * time.Millisecond)
+	}()
+
+	job, err := q.Receive(ctx, db2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(job.Payload) != "job" {
+		t.Errorf("got %q", job.Payload)
+	}
+}
+
+func TestDB(t *testing.T) {
+	t.Parallel()
+
+	db, err := sql.Open("sqlite3", "file:"+filepath.ToSlash(filepath.Join(t.TempDir(), "test.db"))+"?_pragma=busy_timeout(5000)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	q := queue.DB{Queue: queue.New("test", nil), DB: db}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err = q.Init(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan struct{})
+	defer func() { <-done }()
+	go func() {
+		defer close(done)
+		time.Sleep(50 * time.Millisecond)
+		q.Enqueue(ctx, []byte("job"), nil)
+	}()
+
+	job, err := q.Receive(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(job.Payload) != "job" {
+		t.Errorf("got %q", job.Payload)
+	}
+	err = q.Ack(ctx, job)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	job, err = q.Dequeue(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job != nil {
+		t.Errorf("got %+v, want nil", job)
+	}
+}
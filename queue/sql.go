@@ -0,0 +1,87 @@
+package queue
+
+import (
+	"strings"
+
+	"github.com/ncruces/go-sqlite3"
+)
+
+type statements struct {
+	create      string
+	enqueue     string
+	next        string
+	nextVisible string
+	lease       string
+	leased      string
+	ack         string
+	release     string
+	bury        string
+	delete      string
+}
+
+func newStatements(table string) statements {
+	r := strings.NewReplacer(
+		"$table", sqlite3.QuoteIdentifier(table),
+		"$dead", sqlite3.QuoteIdentifier(table+"_dead"),
+		"$index", sqlite3.QuoteIdentifier(table+"_visible"))
+
+	return statements{
+		create: r.Replace(`
+			CREATE TABLE IF NOT EXISTS $table (
+				id         INTEGER PRIMARY KEY AUTOINCREMENT,
+				queue      TEXT NOT NULL,
+				payload    BLOB,
+				priority   INTEGER NOT NULL,
+				attempts   INTEGER NOT NULL DEFAULT 0,
+				visible_at INTEGER NOT NULL,
+				lease      INTEGER,
+				created_at INTEGER NOT NULL
+			);
+			CREATE INDEX IF NOT EXISTS $index ON $table (queue, visible_at);
+			CREATE TABLE IF NOT EXISTS $dead (
+				id         INTEGER PRIMARY KEY,
+				queue      TEXT NOT NULL,
+				payload    BLOB,
+				priority   INTEGER NOT NULL,
+				attempts   INTEGER NOT NULL,
+				created_at INTEGER NOT NULL,
+				failed_at  INTEGER NOT NULL,
+				error      TEXT
+			);`),
+
+		enqueue: r.Replace(`
+			INSERT INTO $table (queue, payload, priority, visible_at, created_at)
+			VALUES (?, ?, ?, ?, ?)`),
+
+		next: r.Replace(`
+			SELECT id, payload, priority, attempts, lease IS NULL FROM $table
+			WHERE queue = ? AND visible_at <= ?
+			ORDER BY priority DESC, visible_at, id
+			LIMIT 1`),
+
+		nextVisible: r.Replace(`
+			SELECT min(visible_at) FROM $table WHERE queue = ?`),
+
+		lease: r.Replace(`
+			UPDATE $table SET attempts = attempts + 1, visible_at = ?, lease = ?
+			WHERE id = ?`),
+
+		leased: r.Replace(`
+			SELECT 1 FROM $table WHERE id = ? AND lease = ?`),
+
+		ack: r.Replace(`
+			DELETE FROM $table WHERE id = ? AND lease = ?`),
+
+		release: r.Replace(`
+			UPDATE $table SET visible_at = ?, lease = NULL
+			WHERE id = ? AND lease = ?`),
+
+		bury: r.Replace(`
+			INSERT INTO $dead (id, queue, payload, priority, attempts, created_at, failed_at, error)
+			SELECT id, queue, payload, priority, attempts, created_at, ?2, ?1
+			FROM $table WHERE id = ?3`),
+
+		delete: r.Replace(`
+			DELETE FROM $table WHERE id = ?`),
+	}
+}
@@ -0,0 +1,146 @@
+package sqlite3
+
+import (
+	"context"
+	"io"
+
+	"github.com/ncruces/go-sqlite3/internal/util"
+	"github.com/tetratelabs/wazero/api"
+)
+
+// RecoverOptions configure [Conn.Recover] and [Conn.RecoverSQL].
+//
+// https://sqlite.org/recovery.html
+type RecoverOptions struct {
+	// Schema is the name of the database to recover.
+	// If empty, "main" is used.
+	Schema string
+	// LostAndFound is the name of the table where orphaned rows are stored.
+	// If empty, orphaned rows are discarded.
+	LostAndFound string
+	// FreelistCorrupt assumes the freelist is corrupt,
+	// and attempts to recover rows from pages that appear to be free.
+	FreelistCorrupt bool
+	// NoRowIDs does not preserve the rowid of rows
+	// in tables without an INTEGER PRIMARY KEY.
+	NoRowIDs bool
+	// SlowIndexes creates indexes before populating tables.
+	SlowIndexes bool
+}
+
+// Recover attempts to recover as much data as possible
+// from a corrupt database, into the database in dstURI.
+//
+// https://sqlite.org/recovery.html
+func (c *Conn) Recover(dstURI string, opts *RecoverOptions) error {
+	if err := c.exports("sqlite3_recover_init"); err != nil {
+		return err
+	}
+	defer c.arena.mark()()
+	schemaPtr := c.arena.string(recoverSchema(opts))
+	dstPtr := c.arena.string(dstURI)
+
+	r := c.call("sqlite3_recover_init", uint64(c.handle), uint64(schemaPtr), uint64(dstPtr))
+	return c.recover(uint32(r), opts)
+}
+
+// RecoverSQL attempts to recover as much data as possible
+// from a corrupt database, writing SQL statements that recreate it to w.
+//
+// https://sqlite.org/recovery.html
+func (c *Conn) RecoverSQL(w io.Writer, opts *RecoverOptions) error {
+	if err := c.exports("sqlite3_recover_init_sql_go"); err != nil {
+		return err
+	}
+	defer c.arena.mark()()
+	schemaPtr := c.arena.string(recoverSchema(opts))
+
+	out := &recoverOutput{w: w}
+	outPtr := util.AddHandle(c.ctx, out)
+	defer util.DelHandle(c.ctx, outPtr)
+
+	r := c.call("sqlite3_recover_init_sql_go", uint64(c.handle), uint64(schemaPtr), uint64(outPtr))
+	if err := c.recover(uint32(r), opts); err != nil {
+		if out.err != nil {
+			return out.err
+		}
+		return err
+	}
+	return out.err
+}
+
+func (c *Conn) recover(ptr uint32, opts *RecoverOptions) error {
+	if ptr == 0 {
+		panic(util.OOMErr)
+	}
+
+	config := func(op uint32, arg uint64) {
+		c.call("sqlite3_recover_config", uint64(ptr), uint64(op), arg)
+	}
+	flag := func(op uint32, v bool) {
+		var i uint32
+		if v {
+			i = 1
+		}
+		argPtr := c.arena.new(4)
+		util.WriteUint32(c.mod, argPtr, i)
+		config(op, uint64(argPtr))
+	}
+
+	if opts != nil {
+		if opts.LostAndFound != "" {
+			config(_RECOVER_LOST_AND_FOUND, uint64(c.arena.string(opts.LostAndFound)))
+		}
+		flag(_RECOVER_FREELIST_CORRUPT, opts.FreelistCorrupt)
+		flag(_RECOVER_ROWIDS, !opts.NoRowIDs)
+		flag(_RECOVER_SLOWINDEXES, opts.SlowIndexes)
+	}
+
+	c.call("sqlite3_recover_run", uint64(ptr))
+
+	var msg string
+	rc := c.call("sqlite3_recover_errcode", uint64(ptr))
+	if rc != _OK {
+		if r := c.call("sqlite3_recover_errmsg", uint64(ptr)); r != 0 {
+			msg = util.ReadString(c.mod, uint32(r), _MAX_LENGTH)
+		}
+	}
+
+	rc = c.call("sqlite3_recover_finish", uint64(ptr))
+	err := c.sqlite.error(rc, 0)
+	if err, ok := err.(*Error); ok && msg != err.str {
+		err.msg = msg
+	}
+	return err
+}
+
+const (
+	_RECOVER_LOST_AND_FOUND   = 1
+	_RECOVER_FREELIST_CORRUPT = 2
+	_RECOVER_ROWIDS           = 3
+	_RECOVER_SLOWINDEXES      = 4
+)
+
+func recoverSchema(opts *RecoverOptions) string {
+	if opts == nil || opts.Schema == "" {
+		return "main"
+	}
+	return opts.Schema
+}
+
+type recoverOutput struct {
+	w   io.Writer
+	err error
+}
+
+func recoverSQLCallback(ctx context.Context, mod api.Module, pApp, zSQL uint32) uint32 {
+	out := util.GetHandle(ctx, pApp).(*recoverOutput)
+	if out.err == nil {
+		sql := util.ReadString(mod, zSQL, _MAX_SQL_LENGTH)
+		_, out.err = io.WriteString(out.w, sql+";\n")
+	}
+	if out.err != nil {
+		return uint32(ERROR)
+	}
+	return _OK
+}
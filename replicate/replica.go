@@ -0,0 +1,126 @@
+package replicate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ObjectType is the type of an [Object] stored in a [Replica].
+type ObjectType uint8
+
+const (
+	// SNAPSHOT objects are complete database files.
+	SNAPSHOT ObjectType = iota + 1
+	// SEGMENT objects are sequences of committed WAL frames.
+	SEGMENT
+)
+
+// Object describes a snapshot or segment stored in a [Replica].
+type Object struct {
+	Type ObjectType
+	// TXID is the last transaction included in the object.
+	TXID uint64
+	// Time is the time of the last transaction included in the object.
+	Time time.Time
+}
+
+// A Replica stores snapshots and segments of a replicated database.
+type Replica interface {
+	// List returns all objects in the replica, in any order.
+	List(ctx context.Context) ([]Object, error)
+	// Write stores an object.
+	Write(ctx context.Context, obj Object, r io.Reader) error
+	// Open retrieves an object.
+	Open(ctx context.Context, obj Object) (io.ReadCloser, error)
+}
+
+// Dir is a [Replica] that stores objects as files in a local directory.
+type Dir string
+
+func (d Dir) name(obj Object) string {
+	ext := ".snapshot"
+	if obj.Type == SEGMENT {
+		ext = ".segment"
+	}
+	return filepath.Join(string(d), fmt.Sprintf("%016x-%d%s", obj.TXID, obj.Time.UnixNano(), ext))
+}
+
+// List implements [Replica].
+func (d Dir) List(ctx context.Context) ([]Object, error) {
+	entries, err := os.ReadDir(string(d))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var res []Object
+	for _, e := range entries {
+		var obj Object
+		name := e.Name()
+		switch filepath.Ext(name) {
+		case ".snapshot":
+			obj.Type = SNAPSHOT
+		case ".segment":
+			obj.Type = SEGMENT
+		default:
+			continue
+		}
+
+		txid, nano, ok := strings.Cut(strings.TrimSuffix(name, filepath.Ext(name)), "-")
+		if !ok {
+			continue
+		}
+		var err error
+		obj.TXID, err = strconv.ParseUint(txid, 16, 64)
+		if err != nil {
+			continue
+		}
+		n, err := strconv.ParseInt(nano, 10, 64)
+		if err != nil {
+			continue
+		}
+		obj.Time = time.Unix(0, n)
+		res = append(res, obj)
+	}
+	return res, nil
+}
+
+// Write implements [Replica].
+func (d Dir) Write(ctx context.Context, obj Object, r io.Reader) error {
+	if err := os.MkdirAll(string(d), 0777); err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(string(d), "*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	if _, err := io.Copy(f, r); err != nil {
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), d.name(obj))
+}
+
+// Open implements [Replica].
+func (d Dir) Open(ctx context.Context, obj Object) (io.ReadCloser, error) {
+	return os.Open(d.name(obj))
+}
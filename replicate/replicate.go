@@ -0,0 +1,327 @@
+// Package replicate continuously replicates SQLite databases.
+//
+// A [Replicator] attaches to the connection that writes to a database in WAL mode,
+// copies committed WAL frames to a [Replica] after every transaction,
+// and periodically stores snapshots of the database.
+// [Restore] recreates the database as of any replicated transaction,
+// or point in time.
+//
+// Replication reads the database and WAL files directly,
+// so the database must use the default OS VFS.
+// All writes must go through the replicated connection,
+// and other connections must not checkpoint the database.
+package replicate
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ncruces/go-sqlite3"
+	"github.com/ncruces/go-sqlite3/util/fileformat"
+)
+
+// Options configure a [Replicator].
+type Options struct {
+	// Schema is the name of the database to replicate.
+	// If empty, "main" is used.
+	Schema string
+	// SnapshotInterval is the minimum time between snapshots.
+	// If zero, snapshots are only taken by [Attach] and [Replicator.Snapshot].
+	SnapshotInterval time.Duration
+	// CheckpointPages is the size of the WAL, in pages,
+	// that triggers a checkpoint.
+	// If zero, 1000 pages are used.
+	CheckpointPages int
+}
+
+// Replicator replicates a database to a [Replica].
+type Replicator struct {
+	conn    *sqlite3.Conn
+	replica Replica
+	opts    Options
+
+	wal      *walFile
+	walr     *fileformat.WALReader
+	pending  []*fileformat.WALFrame // frames read, but not yet shipped
+	read     int                    // frames read from the current WAL
+	dbPath   string
+	autoCkpt int
+	txid     uint64
+	snapshot time.Time
+}
+
+// Attach starts replicating the database on c to replica,
+// storing a snapshot of the database.
+//
+// Attach disables automatic checkpoints, until [Replicator.Detach],
+// and replaces the WAL hook of c.
+// The WAL hook is used to ship transactions and checkpoint the database,
+// so c can't also be hooked by
+// [github.com/ncruces/go-sqlite3/maintenance.Scheduler.Hook].
+// The database must be in WAL mode.
+func Attach(c *sqlite3.Conn, replica Replica, opts *Options) (*Replicator, error) {
+	r := &Replicator{conn: c, replica: replica}
+	if opts != nil {
+		r.opts = *opts
+	}
+	if r.opts.Schema == "" {
+		r.opts.Schema = "main"
+	}
+	if r.opts.CheckpointPages == 0 {
+		r.opts.CheckpointPages = 1000
+	}
+
+	mode, err := journalMode(c, r.opts.Schema)
+	if err != nil {
+		return nil, err
+	}
+	if mode != "wal" {
+		return nil, errors.New("replicate: database is not in WAL mode")
+	}
+
+	name := c.Filename(r.opts.Schema)
+	if name == nil {
+		return nil, errors.New("replicate: database is not a file")
+	}
+	r.dbPath = name.String()
+	r.autoCkpt, err = autoCheckpoint(c)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(name.WAL())
+	if err != nil {
+		return nil, err
+	}
+	r.wal = &walFile{File: f}
+
+	objs, err := replica.List(context.Background())
+	if err != nil {
+		r.wal.Close()
+		return nil, err
+	}
+	for _, obj := range objs {
+		r.txid = max(r.txid, obj.TXID)
+	}
+
+	_, err = c.FileControl(r.opts.Schema, sqlite3.FCNTL_PERSIST_WAL, true)
+	if err == nil {
+		err = c.WalAutoCheckpoint(0)
+	}
+	if err == nil {
+		// The database may have changed since it was last replicated:
+		// the snapshot is a new transaction.
+		r.txid++
+		err = r.snapshotDB()
+	}
+	if err != nil {
+		r.wal.Close()
+		return nil, err
+	}
+
+	c.WalHook(r.hook)
+	return r, nil
+}
+
+// Detach stops replicating the database,
+// removes the WAL hook, and restores automatic checkpoints
+// to their setting before [Attach].
+func (r *Replicator) Detach() error {
+	r.conn.WalHook(nil)
+	err := r.conn.WalAutoCheckpoint(r.autoCkpt)
+	if cerr := r.wal.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// TXID returns the ID of the last replicated transaction.
+func (r *Replicator) TXID() uint64 {
+	return r.txid
+}
+
+// Snapshot checkpoints the database, and stores a snapshot of it.
+func (r *Replicator) Snapshot() error {
+	if err := r.ship(-1); err != nil {
+		return err
+	}
+	return r.snapshotDB()
+}
+
+func (r *Replicator) snapshotDB() error {
+	nLog, nCkpt, err := r.conn.WalCheckpoint(r.opts.Schema, sqlite3.CHECKPOINT_TRUNCATE)
+	if err != nil {
+		return err
+	}
+	if nLog != nCkpt {
+		return sqlite3.BUSY
+	}
+	// The WAL was truncated.
+	r.walr = nil
+	r.pending = nil
+
+	f, err := os.Open(r.dbPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	now := time.Now()
+	err = r.replica.Write(context.Background(), Object{Type: SNAPSHOT, TXID: r.txid, Time: now}, f)
+	if err != nil {
+		return err
+	}
+	r.snapshot = now
+	return nil
+}
+
+func (r *Replicator) hook(c *sqlite3.Conn, schema string, pages int) error {
+	if schema != r.opts.Schema {
+		return nil
+	}
+	if err := r.ship(pages); err != nil {
+		return err
+	}
+	if r.opts.SnapshotInterval > 0 && time.Since(r.snapshot) >= r.opts.SnapshotInterval {
+		if err := r.Snapshot(); err != nil && !errors.Is(err, sqlite3.BUSY) {
+			return err
+		}
+		return nil
+	}
+	if pages >= r.opts.CheckpointPages {
+		_, _, err := c.WalCheckpoint(schema, sqlite3.CHECKPOINT_PASSIVE)
+		return err
+	}
+	return nil
+}
+
+const segmentMagic = "SQLRPL01"
+
+// ship copies the committed frames among the first frames of the WAL
+// to a new segment.
+// If frames is negative, all committed frames are copied.
+func (r *Replicator) ship(frames int) error {
+	var buf [fileformat.WALHeaderSize]byte
+	if _, err := r.wal.ReadAt(buf[:], 0); err != nil {
+		if err == io.EOF {
+			// The WAL is empty.
+			r.walr = nil
+			r.pending = nil
+			return nil
+		}
+		return err
+	}
+	header, err := fileformat.ParseWALHeader(buf[:])
+	if err != nil {
+		return err
+	}
+
+	// The WAL was restarted.
+	if r.walr == nil || r.walr.Header().Salt1 != header.Salt1 || r.walr.Header().Salt2 != header.Salt2 {
+		r.wal.off = 0
+		r.walr, err = fileformat.NewWALReader(r.wal)
+		if err != nil {
+			return err
+		}
+		r.pending = nil
+		r.read = 0
+	}
+
+	for frames < 0 || r.read < frames {
+		off := r.wal.off
+		frame, err := r.walr.Next()
+		if err != nil {
+			// Read the frame again next time.
+			r.wal.off = off
+			if err == io.EOF || errors.Is(err, fileformat.ErrSalt) || errors.Is(err, fileformat.ErrChecksum) {
+				// Frames not yet written, or left over from a previous WAL.
+				break
+			}
+			return err
+		}
+		r.pending = append(r.pending, frame)
+		r.read++
+	}
+
+	committed := 0
+	for i, frame := range r.pending {
+		if frame.IsCommit() {
+			committed = i + 1
+		}
+	}
+	if committed == 0 {
+		return nil
+	}
+
+	var seg bytes.Buffer
+	seg.WriteString(segmentMagic)
+	binary.Write(&seg, binary.BigEndian, uint32(r.walr.Header().PageSize))
+	binary.Write(&seg, binary.BigEndian, r.txid+1)
+
+	now := time.Now()
+	txid := r.txid
+	for _, frame := range r.pending[:committed] {
+		var nano int64
+		if frame.IsCommit() {
+			nano = now.UnixNano()
+			txid++
+		}
+		binary.Write(&seg, binary.BigEndian, frame.PageNumber)
+		binary.Write(&seg, binary.BigEndian, frame.Commit)
+		binary.Write(&seg, binary.BigEndian, nano)
+		seg.Write(frame.Data)
+	}
+
+	err = r.replica.Write(context.Background(), Object{Type: SEGMENT, TXID: txid, Time: now}, &seg)
+	if err != nil {
+		return err
+	}
+	r.txid = txid
+	r.pending = append(r.pending[:0], r.pending[committed:]...)
+	return nil
+}
+
+// walFile reads the WAL sequentially from off.
+type walFile struct {
+	*os.File
+	off int64
+}
+
+func (f *walFile) Read(p []byte) (int, error) {
+	n, err := f.ReadAt(p, f.off)
+	f.off += int64(n)
+	return n, err
+}
+
+func autoCheckpoint(c *sqlite3.Conn) (int, error) {
+	stmt, _, err := c.Prepare(`PRAGMA wal_autocheckpoint`)
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+
+	var pages int
+	if stmt.Step() {
+		pages = stmt.ColumnInt(0)
+	}
+	return pages, stmt.Err()
+}
+
+func journalMode(c *sqlite3.Conn, schema string) (string, error) {
+	stmt, _, err := c.Prepare(`PRAGMA ` + sqlite3.QuoteIdentifier(schema) + `.journal_mode`)
+	if err != nil {
+		return "", err
+	}
+	defer stmt.Close()
+
+	var mode string
+	if stmt.Step() {
+		mode = strings.ToLower(stmt.ColumnText(0))
+	}
+	return mode, stmt.Err()
+}
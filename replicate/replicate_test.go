@@ -0,0 +1,159 @@
+package replicate_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ncruces/go-sqlite3"
+	_ "github.com/ncruces/go-sqlite3/embed"
+	"github.com/ncruces/go-sqlite3/replicate"
+)
+
+func TestReplicator(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	replica := replicate.Dir(filepath.Join(dir, "replica"))
+
+	db, err := sqlite3.Open(filepath.Join(dir, "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	err = db.Exec(`
+		PRAGMA journal_mode=WAL;
+		CREATE TABLE t (x);
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := replicate.Attach(db, replica, &replicate.Options{CheckpointPages: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var txids []uint64
+	for i := 0; i < 20; i++ {
+		err = db.Exec(`INSERT INTO t VALUES (randomblob(2000))`)
+		if err != nil {
+			t.Fatal(err)
+		}
+		txids = append(txids, r.TXID())
+	}
+	middle := time.Now()
+	time.Sleep(10 * time.Millisecond)
+
+	err = r.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		err = db.Exec(`INSERT INTO t VALUES (randomblob(2000))`)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = r.Detach()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	count := func(opts *replicate.RestoreOptions) int {
+		t.Helper()
+
+		path := filepath.Join(t.TempDir(), "restored.db")
+		err := replicate.Restore(ctx, replica, path, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		db, err := sqlite3.Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer db.Close()
+
+		stmt, _, err := db.Prepare(`SELECT count(*) FROM t`)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer stmt.Close()
+
+		if !stmt.Step() {
+			t.Fatal(stmt.Err())
+		}
+		return stmt.ColumnInt(0)
+	}
+
+	if got := count(nil); got != 25 {
+		t.Errorf("got %d, want 25", got)
+	}
+	if got := count(&replicate.RestoreOptions{TXID: txids[9]}); got != 10 {
+		t.Errorf("got %d, want 10", got)
+	}
+	if got := count(&replicate.RestoreOptions{Time: middle}); got != 20 {
+		t.Errorf("got %d, want 20", got)
+	}
+
+	err = replicate.Restore(ctx, replica, filepath.Join(dir, "x.db"),
+		&replicate.RestoreOptions{Time: time.Unix(0, 0)})
+	if err == nil {
+		t.Error("want error")
+	}
+}
+
+func TestReplicator_Detach(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	replica := replicate.Dir(filepath.Join(dir, "replica"))
+
+	db, err := sqlite3.Open(filepath.Join(dir, "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	err = db.Exec(`
+		PRAGMA journal_mode=WAL;
+		PRAGMA wal_autocheckpoint=123;
+		CREATE TABLE t (x);
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := replicate.Attach(db, replica, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.Exec(`INSERT INTO t VALUES (1)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := r.TXID(); got != 2 {
+		t.Errorf("got %d, want 2", got)
+	}
+	err = r.Detach()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stmt, _, err := db.Prepare(`PRAGMA wal_autocheckpoint`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stmt.Close()
+
+	if !stmt.Step() {
+		t.Fatal(stmt.Err())
+	}
+	if got := stmt.ColumnInt(0); got != 123 {
+		t.Errorf("got %d, want 123", got)
+	}
+}
@@ -0,0 +1,190 @@
+package replicate
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// RestoreOptions configure [Restore].
+type RestoreOptions struct {
+	// TXID is the last transaction to restore.
+	// If zero, all transactions are restored.
+	TXID uint64
+	// Time is the time of the last transaction to restore.
+	// If zero, all transactions are restored.
+	Time time.Time
+}
+
+// Restore recreates the database file at path from replica,
+// as of the transaction or time chosen by opts.
+//
+// The file at path must not be in use.
+func Restore(ctx context.Context, replica Replica, path string, opts *RestoreOptions) error {
+	var o RestoreOptions
+	if opts != nil {
+		o = *opts
+	}
+	include := func(txid uint64, t time.Time) bool {
+		return (o.TXID == 0 || txid <= o.TXID) && (o.Time.IsZero() || !t.After(o.Time))
+	}
+
+	objs, err := replica.List(ctx)
+	if err != nil {
+		return err
+	}
+	sort.Slice(objs, func(i, j int) bool {
+		if objs[i].TXID != objs[j].TXID {
+			return objs[i].TXID < objs[j].TXID
+		}
+		return objs[i].Type < objs[j].Type
+	})
+
+	// Find the latest snapshot to restore.
+	var snapshot *Object
+	for i := range objs {
+		if objs[i].Type == SNAPSHOT && include(objs[i].TXID, objs[i].Time) {
+			snapshot = &objs[i]
+		}
+	}
+	if snapshot == nil {
+		return errors.New("replicate: no snapshot to restore")
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+"*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	err = restoreSnapshot(ctx, replica, *snapshot, f)
+	if err != nil {
+		return err
+	}
+
+	txid := snapshot.TXID
+	for _, obj := range objs {
+		if obj.Type != SEGMENT || obj.TXID <= txid {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		var done bool
+		txid, done, err = restoreSegment(ctx, replica, obj, f, txid, include)
+		if err != nil {
+			return err
+		}
+		if done {
+			break
+		}
+	}
+
+	if err := f.Sync(); err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	os.Remove(path + "-wal")
+	os.Remove(path + "-shm")
+	return os.Rename(f.Name(), path)
+}
+
+func restoreSnapshot(ctx context.Context, replica Replica, obj Object, f *os.File) error {
+	r, err := replica.Open(ctx, obj)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	_, err = io.Copy(f, r)
+	return err
+}
+
+// restoreSegment applies the transactions in a segment that follow txid,
+// and returns the last applied transaction,
+// and whether a transaction was excluded.
+func restoreSegment(ctx context.Context, replica Replica, obj Object, f *os.File, txid uint64,
+	include func(uint64, time.Time) bool) (_ uint64, done bool, err error) {
+
+	rc, err := replica.Open(ctx, obj)
+	if err != nil {
+		return txid, false, err
+	}
+	defer rc.Close()
+	r := bufio.NewReader(rc)
+
+	var header struct {
+		Magic    [len(segmentMagic)]byte
+		PageSize uint32
+		TXID     uint64
+	}
+	if err := binary.Read(r, binary.BigEndian, &header); err != nil {
+		return txid, false, err
+	}
+	if string(header.Magic[:]) != segmentMagic {
+		return txid, false, errors.New("replicate: invalid segment")
+	}
+	if header.TXID > txid+1 {
+		return txid, false, fmt.Errorf("replicate: missing transactions %d to %d", txid+1, header.TXID-1)
+	}
+
+	type page struct {
+		pgno uint32
+		data []byte
+	}
+	var pending []page
+
+	segTXID := header.TXID
+	pageSize := int64(header.PageSize)
+	for {
+		var frame struct {
+			Pgno   uint32
+			Commit uint32
+			Time   int64
+		}
+		if err := binary.Read(r, binary.BigEndian, &frame); err != nil {
+			if err == io.EOF {
+				return txid, false, nil
+			}
+			return txid, false, err
+		}
+		data := make([]byte, pageSize)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return txid, false, err
+		}
+		pending = append(pending, page{frame.Pgno, data})
+		if frame.Commit == 0 {
+			continue
+		}
+
+		cur := segTXID
+		segTXID++
+		if cur <= txid {
+			// Already applied by the snapshot.
+			pending = pending[:0]
+			continue
+		}
+		if !include(cur, time.Unix(0, frame.Time)) {
+			return txid, true, nil
+		}
+		for _, p := range pending {
+			if _, err := f.WriteAt(p.data, int64(p.pgno-1)*pageSize); err != nil {
+				return txid, false, err
+			}
+		}
+		if err := f.Truncate(int64(frame.Commit) * pageSize); err != nil {
+			return txid, false, err
+		}
+		pending = pending[:0]
+		txid = cur
+	}
+}
@@ -0,0 +1,98 @@
+package sqlite3
+
+import (
+	"context"
+
+	"github.com/ncruces/go-sqlite3/internal/util"
+	"github.com/tetratelabs/wazero/api"
+)
+
+// CreateGeometry registers a new R*Tree query callback name.
+//
+// Geometry functions can be used to filter R*Tree queries:
+//
+//	SELECT id FROM rtree WHERE id MATCH name(...)
+//
+// https://sqlite.org/rtree.html#custom_r_tree_queries
+func (c *Conn) CreateGeometry(name string, fn GeometryFunction) error {
+	if err := c.exports("sqlite3_rtree_query_callback_go"); err != nil {
+		return err
+	}
+	defer c.arena.mark()()
+	namePtr := c.arena.string(name)
+	funcPtr := util.AddHandle(c.ctx, fn)
+	r := c.call("sqlite3_rtree_query_callback_go",
+		uint64(c.handle), uint64(namePtr), uint64(funcPtr))
+	return c.error(r)
+}
+
+// GeometryFunction is the type of an R*Tree query callback.
+//
+// The callback is invoked for each node and entry of the R*Tree
+// that may match the query,
+// and returns whether the bounding box is within the geometry,
+// and a score: nodes and entries with lower scores are visited first.
+// Implementations must not retain q.
+type GeometryFunction func(q *RTreeQuery) (within RTreeWithin, score float64, err error)
+
+// RTreeQuery is the information available to a [GeometryFunction].
+//
+// https://sqlite.org/rtree.html#the_new_xqueryfunc_callback
+type RTreeQuery struct {
+	// Params are the arguments to the SQL function.
+	Params []float64
+	// Coords are the bounding box coordinates of the node or entry,
+	// as pairs of minimum and maximum values for each dimension.
+	Coords []float64
+	// Level of the node or entry: entries are at level 0.
+	Level int
+	// MaxLevel is the level of the root node.
+	MaxLevel int
+	// RowID of the entry; only valid for entries.
+	RowID int64
+	// ParentWithin is the within value of the parent node.
+	ParentWithin RTreeWithin
+	// ParentScore is the score of the parent node.
+	ParentScore float64
+}
+
+// RTreeWithin is the result of a [GeometryFunction].
+//
+// https://sqlite.org/rtree.html#the_new_xqueryfunc_callback
+type RTreeWithin uint32
+
+const (
+	NOT_WITHIN    RTreeWithin = 0 /* Object completely outside of query region */
+	PARTLY_WITHIN RTreeWithin = 1 /* Object partially overlaps query region */
+	FULLY_WITHIN  RTreeWithin = 2 /* Object fully contained within query region */
+)
+
+func rtreeQueryCallback(ctx context.Context, mod api.Module, pApp, pInfo uint32) uint32 {
+	fn := util.GetHandle(ctx, pApp).(GeometryFunction)
+
+	// https://sqlite.org/rtree.html#the_new_xqueryfunc_callback
+	var q RTreeQuery
+	q.Params = make([]float64, util.ReadUint32(mod, pInfo+4))
+	paramPtr := util.ReadUint32(mod, pInfo+8)
+	for i := range q.Params {
+		q.Params[i] = util.ReadFloat64(mod, paramPtr+uint32(i)*8)
+	}
+	q.Coords = make([]float64, util.ReadUint32(mod, pInfo+28))
+	coordPtr := util.ReadUint32(mod, pInfo+20)
+	for i := range q.Coords {
+		q.Coords[i] = util.ReadFloat64(mod, coordPtr+uint32(i)*8)
+	}
+	q.Level = int(int32(util.ReadUint32(mod, pInfo+32)))
+	q.MaxLevel = int(int32(util.ReadUint32(mod, pInfo+36)))
+	q.RowID = int64(util.ReadUint64(mod, pInfo+40))
+	q.ParentScore = util.ReadFloat64(mod, pInfo+48)
+	q.ParentWithin = RTreeWithin(util.ReadUint32(mod, pInfo+56))
+
+	within, score, err := fn(&q)
+	if err == nil {
+		util.WriteUint32(mod, pInfo+60, uint32(within))
+		util.WriteFloat64(mod, pInfo+64, score)
+	}
+	_, code := errorCode(err, ERROR)
+	return code
+}
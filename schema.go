@@ -0,0 +1,539 @@
+package sqlite3
+
+import (
+	"strings"
+
+	"github.com/ncruces/go-sqlite3/internal/util"
+	"github.com/ncruces/go-sqlite3/util/vtabutil"
+)
+
+// Schema describes the tables, views and triggers of a database schema.
+// Internal schema objects (those named "sqlite_%") are not included.
+type Schema struct {
+	Tables   []Table
+	Views    []View
+	Triggers []Trigger
+}
+
+// Table describes a table.
+type Table struct {
+	Name         string
+	SQL          string
+	Virtual      bool
+	WithoutRowID bool
+	Strict       bool
+	Columns      []Column
+	Indexes      []Index
+	ForeignKeys  []ForeignKey
+}
+
+// View describes a view.
+type View struct {
+	Name    string
+	SQL     string
+	Columns []Column
+}
+
+// Trigger describes a trigger.
+type Trigger struct {
+	Name  string
+	Table string
+	SQL   string
+}
+
+// Column describes a column of a table or view.
+type Column struct {
+	Name string
+	// Type is the declared type of the column.
+	Type     string
+	Affinity Affinity
+	// Collation is the collating sequence of the column,
+	// or empty if it can't be determined.
+	Collation string
+	NotNull   bool
+	// Default is the SQL text of the default value of the column,
+	// or empty if it has no default value.
+	Default string
+	// PrimaryKey is the 1-based position of the column
+	// in the primary key, or zero.
+	PrimaryKey    int
+	AutoIncrement bool
+	// Hidden is true for hidden columns of virtual tables.
+	Hidden bool
+	// Generated is "VIRTUAL" or "STORED" for generated columns,
+	// and empty otherwise.
+	Generated string
+}
+
+// Index describes an index.
+type Index struct {
+	Name string
+	// SQL is empty for indexes created by UNIQUE
+	// and PRIMARY KEY constraints.
+	SQL    string
+	Unique bool
+	// Origin is "c" if the index was created by a CREATE INDEX statement,
+	// "u" if created by a UNIQUE constraint,
+	// or "pk" if created by a PRIMARY KEY constraint.
+	Origin  string
+	Columns []IndexColumn
+	// Where is the SQL text of the WHERE clause of a partial index.
+	Where string
+}
+
+// IndexColumn describes a key column of an index.
+type IndexColumn struct {
+	// Name is empty for expressions.
+	Name string
+	// Expr is the SQL text of an expression, if known.
+	Expr      string
+	Desc      bool
+	Collation string
+}
+
+// ForeignKey describes a foreign key constraint.
+type ForeignKey struct {
+	Columns []string
+	Table   string
+	// RefColumns are empty if the constraint refers
+	// to the primary key of Table.
+	RefColumns []string
+	OnUpdate   string
+	OnDelete   string
+	Match      string
+}
+
+// Affinity is the type affinity of a column.
+//
+// https://sqlite.org/datatype3.html#type_affinity
+type Affinity string
+
+const (
+	AFF_BLOB    Affinity = "BLOB"
+	AFF_TEXT    Affinity = "TEXT"
+	AFF_NUMERIC Affinity = "NUMERIC"
+	AFF_INTEGER Affinity = "INTEGER"
+	AFF_REAL    Affinity = "REAL"
+)
+
+// Schema returns a description of the schema of the database named schema
+// (e.g. "main", "temp", or the name of an attached database).
+// If schema is empty, "main" is used.
+//
+// https://sqlite.org/schematab.html
+func (c *Conn) Schema(schema string) (*Schema, error) {
+	if schema == "" {
+		schema = "main"
+	}
+
+	stmt, _, err := c.Prepare(`
+		SELECT s.type, s.name, s.tbl_name, s.sql, l.type, l.wr, l.strict
+		FROM ` + QuoteIdentifier(schema) + `.sqlite_schema AS s
+		LEFT JOIN pragma_table_list AS l ON l.schema = ?1 AND l.name = s.name
+		WHERE s.name NOT LIKE 'sqlite\_%' ESCAPE '\'
+		ORDER BY s.rowid`)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	var res Schema
+	var indexes = map[string]string{}
+	stmt.BindText(1, schema)
+	for stmt.Step() {
+		name := stmt.ColumnText(1)
+		sql := stmt.ColumnText(3)
+		switch stmt.ColumnText(0) {
+		case "table":
+			if stmt.ColumnText(4) == "shadow" {
+				continue
+			}
+			res.Tables = append(res.Tables, Table{
+				Name:         name,
+				SQL:          sql,
+				Virtual:      stmt.ColumnText(4) == "virtual",
+				WithoutRowID: stmt.ColumnBool(5),
+				Strict:       stmt.ColumnBool(6),
+			})
+		case "view":
+			res.Views = append(res.Views, View{Name: name, SQL: sql})
+		case "trigger":
+			res.Triggers = append(res.Triggers, Trigger{
+				Name:  name,
+				Table: stmt.ColumnText(2),
+				SQL:   sql,
+			})
+		case "index":
+			indexes[name] = sql
+		}
+	}
+	if err := stmt.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range res.Tables {
+		tab := &res.Tables[i]
+		tab.Columns, err = c.schemaColumns(schema, tab.Name, tab.SQL, tab.Strict)
+		if err != nil {
+			return nil, err
+		}
+		if tab.Virtual {
+			continue
+		}
+		tab.Indexes, err = c.schemaIndexes(schema, tab.Name, indexes)
+		if err != nil {
+			return nil, err
+		}
+		tab.ForeignKeys, err = c.schemaForeignKeys(schema, tab.Name)
+		if err != nil {
+			return nil, err
+		}
+	}
+	for i := range res.Views {
+		view := &res.Views[i]
+		view.Columns, err = c.schemaColumns(schema, view.Name, "", false)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &res, nil
+}
+
+func (c *Conn) schemaColumns(schema, table, sql string, strict bool) ([]Column, error) {
+	stmt, _, err := c.Prepare(`
+		SELECT name, type, "notnull", dflt_value, pk, hidden
+		FROM pragma_table_xinfo(?1, ?2)`)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	var cols []Column
+	stmt.BindText(1, table)
+	stmt.BindText(2, schema)
+	for stmt.Step() {
+		col := Column{
+			Name:       stmt.ColumnText(0),
+			Type:       stmt.ColumnText(1),
+			NotNull:    stmt.ColumnBool(2),
+			Default:    stmt.ColumnText(3),
+			PrimaryKey: stmt.ColumnInt(4),
+		}
+		col.Affinity = affinity(col.Type, strict)
+		switch stmt.ColumnInt(5) {
+		case 1:
+			col.Hidden = true
+		case 2:
+			col.Generated = "VIRTUAL"
+		case 3:
+			col.Generated = "STORED"
+		}
+		cols = append(cols, col)
+	}
+	if err := stmt.Err(); err != nil {
+		return nil, err
+	}
+
+	if sql == "" {
+		return cols, nil
+	}
+
+	// AUTOINCREMENT is only allowed on an INTEGER PRIMARY KEY.
+	pk := -1
+	for i, col := range cols {
+		if col.PrimaryKey != 0 {
+			if pk >= 0 {
+				pk = -1
+				break
+			}
+			pk = i
+		}
+	}
+	if pk >= 0 {
+		cols[pk].AutoIncrement = autoIncrement(sql)
+	}
+
+	// Collations are only available from the table definition.
+	if tab, err := vtabutil.Parse(sql); err == nil && len(tab.Columns) == len(cols) {
+		for i, col := range tab.Columns {
+			if col.CollateName != "" {
+				cols[i].Collation = col.CollateName
+			} else {
+				cols[i].Collation = "BINARY"
+			}
+		}
+	}
+	return cols, nil
+}
+
+func (c *Conn) schemaIndexes(schema, table string, sql map[string]string) ([]Index, error) {
+	stmt, _, err := c.Prepare(`
+		SELECT name, "unique", origin
+		FROM pragma_index_list(?1, ?2)`)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	var idxs []Index
+	stmt.BindText(1, table)
+	stmt.BindText(2, schema)
+	for stmt.Step() {
+		name := stmt.ColumnText(0)
+		idxs = append(idxs, Index{
+			Name:   name,
+			SQL:    sql[name],
+			Unique: stmt.ColumnBool(1),
+			Origin: stmt.ColumnText(2),
+		})
+	}
+	if err := stmt.Err(); err != nil {
+		return nil, err
+	}
+	if err := stmt.Close(); err != nil {
+		return nil, err
+	}
+
+	stmt, _, err = c.Prepare(`
+		SELECT cid, name, "desc", coll
+		FROM pragma_index_xinfo(?1, ?2)
+		WHERE key
+		ORDER BY seqno`)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	for i := range idxs {
+		idx := &idxs[i]
+		exprs, where := splitIndexSQL(idx.SQL)
+		idx.Where = where
+
+		stmt.BindText(1, idx.Name)
+		stmt.BindText(2, schema)
+		for n := 0; stmt.Step(); n++ {
+			col := IndexColumn{
+				Name:      stmt.ColumnText(1),
+				Desc:      stmt.ColumnBool(2),
+				Collation: stmt.ColumnText(3),
+			}
+			if stmt.ColumnInt(0) == -2 && n < len(exprs) {
+				col.Expr = exprs[n]
+			}
+			idx.Columns = append(idx.Columns, col)
+		}
+		if err := stmt.Reset(); err != nil {
+			return nil, err
+		}
+	}
+	return idxs, nil
+}
+
+func (c *Conn) schemaForeignKeys(schema, table string) ([]ForeignKey, error) {
+	stmt, _, err := c.Prepare(`
+		SELECT id, "table", "from", "to", on_update, on_delete, match
+		FROM pragma_foreign_key_list(?1, ?2)
+		ORDER BY id, seq`)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	var fks []ForeignKey
+	var last int64 = -1
+	stmt.BindText(1, table)
+	stmt.BindText(2, schema)
+	for stmt.Step() {
+		if id := stmt.ColumnInt64(0); id != last {
+			last = id
+			fks = append(fks, ForeignKey{
+				Table:    stmt.ColumnText(1),
+				OnUpdate: stmt.ColumnText(4),
+				OnDelete: stmt.ColumnText(5),
+				Match:    stmt.ColumnText(6),
+			})
+		}
+		fk := &fks[len(fks)-1]
+		fk.Columns = append(fk.Columns, stmt.ColumnText(2))
+		if stmt.ColumnType(3) != NULL {
+			fk.RefColumns = append(fk.RefColumns, stmt.ColumnText(3))
+		}
+	}
+	if err := stmt.Err(); err != nil {
+		return nil, err
+	}
+	return fks, nil
+}
+
+// TableColumnMetadata returns metadata about a column of a table.
+// If schema is empty, all attached databases are searched.
+// If column is empty, only the existence of the table is checked.
+//
+// https://sqlite.org/c3ref/table_column_metadata.html
+func (c *Conn) TableColumnMetadata(schema, table, column string) (declType, collSeq string, notNull, primaryKey, autoInc bool, err error) {
+	if err = c.exports("sqlite3_table_column_metadata"); err != nil {
+		return
+	}
+	defer c.arena.mark()()
+
+	var schemaPtr, columnPtr uint32
+	declTypePtr := c.arena.new(ptrlen)
+	collSeqPtr := c.arena.new(ptrlen)
+	notNullPtr := c.arena.new(ptrlen)
+	primaryKeyPtr := c.arena.new(ptrlen)
+	autoIncPtr := c.arena.new(ptrlen)
+	if schema != "" {
+		schemaPtr = c.arena.string(schema)
+	}
+	tablePtr := c.arena.string(table)
+	if column != "" {
+		columnPtr = c.arena.string(column)
+	}
+
+	r := c.call("sqlite3_table_column_metadata", uint64(c.handle),
+		uint64(schemaPtr), uint64(tablePtr), uint64(columnPtr),
+		uint64(declTypePtr), uint64(collSeqPtr),
+		uint64(notNullPtr), uint64(primaryKeyPtr), uint64(autoIncPtr))
+	if err = c.error(r); err == nil && column != "" {
+		declType = util.ReadString(c.mod, util.ReadUint32(c.mod, declTypePtr), _MAX_NAME)
+		collSeq = util.ReadString(c.mod, util.ReadUint32(c.mod, collSeqPtr), _MAX_NAME)
+		notNull = util.ReadUint32(c.mod, notNullPtr) != 0
+		autoInc = util.ReadUint32(c.mod, autoIncPtr) != 0
+		primaryKey = util.ReadUint32(c.mod, primaryKeyPtr) != 0
+	}
+	return
+}
+
+// https://sqlite.org/datatype3.html#determination_of_column_affinity
+func affinity(typ string, strict bool) Affinity {
+	typ = strings.ToUpper(typ)
+	switch {
+	case strings.Contains(typ, "INT"):
+		return AFF_INTEGER
+	case strings.Contains(typ, "CHAR"),
+		strings.Contains(typ, "CLOB"),
+		strings.Contains(typ, "TEXT"):
+		return AFF_TEXT
+	case typ == "" || strings.Contains(typ, "BLOB"):
+		return AFF_BLOB
+	case strings.Contains(typ, "REAL"),
+		strings.Contains(typ, "FLOA"),
+		strings.Contains(typ, "DOUB"):
+		return AFF_REAL
+	case strict && typ == "ANY":
+		return AFF_BLOB
+	}
+	return AFF_NUMERIC
+}
+
+// splitIndexSQL returns the SQL text of the indexed columns,
+// and of the WHERE clause, of a CREATE INDEX statement.
+func splitIndexSQL(sql string) (exprs []string, where string) {
+	var depth, start int
+	for i := 0; i < len(sql); i++ {
+		switch sql[i] {
+		case '\'', '"', '`':
+			if j := strings.IndexByte(sql[i+1:], sql[i]); j >= 0 {
+				i += j + 1
+			}
+		case '[':
+			if j := strings.IndexByte(sql[i+1:], ']'); j >= 0 {
+				i += j + 1
+			}
+		case '(':
+			depth++
+			if depth == 1 {
+				start = i + 1
+			}
+		case ',':
+			if depth == 1 {
+				exprs = append(exprs, strings.TrimSpace(sql[start:i]))
+				start = i + 1
+			}
+		case ')':
+			depth--
+			if depth == 0 {
+				exprs = append(exprs, strings.TrimSpace(sql[start:i]))
+				rest := strings.TrimSpace(sql[i+1:])
+				if len(rest) > 5 && strings.EqualFold(rest[:5], "WHERE") {
+					where = strings.TrimSpace(rest[5:])
+				}
+				return exprs, where
+			}
+		}
+	}
+	return nil, ""
+}
+
+// autoIncrement reports whether a CREATE TABLE statement
+// uses the AUTOINCREMENT keyword.
+// AUTOINCREMENT can't be an unquoted identifier,
+// so comments, literals and quoted identifiers are skipped.
+func autoIncrement(sql string) bool {
+	for i := 0; i < len(sql); {
+		start, end := sqlToken(sql, i)
+		if strings.EqualFold(sql[start:end], "AUTOINCREMENT") {
+			return true
+		}
+		if start == end {
+			break
+		}
+		i = end
+	}
+	return false
+}
+
+// sqlToken returns the bounds of the next token in sql,
+// skipping whitespace and comments.
+func sqlToken(sql string, i int) (start, end int) {
+loop:
+	for i < len(sql) {
+		switch {
+		case strings.IndexByte(" \t\n\f\r", sql[i]) >= 0:
+			i++
+		case strings.HasPrefix(sql[i:], "--"):
+			if n := strings.IndexByte(sql[i:], '\n'); n >= 0 {
+				i += n + 1
+			} else {
+				i = len(sql)
+			}
+		case strings.HasPrefix(sql[i:], "/*"):
+			if n := strings.Index(sql[i+2:], "*/"); n >= 0 {
+				i += n + 4
+			} else {
+				i = len(sql)
+			}
+		default:
+			break loop
+		}
+	}
+	if i == len(sql) {
+		return i, i
+	}
+
+	start = i
+	switch q := sql[i]; q {
+	case '"', '\'', '`', '[':
+		if q == '[' {
+			q = ']'
+		}
+		for i++; i < len(sql); i++ {
+			if sql[i] == q {
+				if i+1 < len(sql) && sql[i+1] == q && q != ']' {
+					i++
+					continue
+				}
+				return start, i + 1
+			}
+		}
+		return start, len(sql)
+	}
+	for i < len(sql) && (sql[i] == '_' || sql[i] >= 0x80 ||
+		'a' <= sql[i]|0x20 && sql[i]|0x20 <= 'z' || '0' <= sql[i] && sql[i] <= '9' || sql[i] == '$') {
+		i++
+	}
+	if i == start {
+		i++
+	}
+	return start, i
+}
@@ -0,0 +1,665 @@
+// Package sqldiff compares SQLite databases,
+// like the [sqldiff] utility.
+//
+// The output is an SQL script that transforms
+// the schema and data of one database into the other.
+// The script should be run in a transaction, with foreign keys disabled:
+// see [github.com/ncruces/go-sqlite3/migrate.Migration].
+//
+// Changesets are not supported:
+// the session extension is not compiled into the embedded binary.
+//
+// [sqldiff]: https://sqlite.org/sqldiff.html
+package sqldiff
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/ncruces/go-sqlite3"
+)
+
+// Options configure a [Diff].
+type Options struct {
+	// Schema is the name of the schema to compare.
+	// If empty, "main" is used.
+	Schema string
+	// SchemaOnly compares only the schema.
+	SchemaOnly bool
+	// DataOnly compares only the data of tables present in both databases.
+	DataOnly bool
+}
+
+// Diff writes to w the SQL statements that transform
+// the database src into the database dst.
+//
+// Tables, indexes, views and triggers are compared by name.
+// Changed tables are recreated, following the
+// [generalized ALTER TABLE procedure].
+// Rows are compared by primary key, or by rowid.
+// Virtual tables are compared by schema only.
+//
+// [generalized ALTER TABLE procedure]: https://sqlite.org/lang_altertable.html#otheralter
+func Diff(w io.Writer, src, dst *sqlite3.Conn, opts *Options) error {
+	var o Options
+	if opts != nil {
+		o = *opts
+	}
+	if o.Schema == "" {
+		o.Schema = "main"
+	}
+
+	srcSchema, err := src.Schema(o.Schema)
+	if err != nil {
+		return err
+	}
+	dstSchema, err := dst.Schema(o.Schema)
+	if err != nil {
+		return err
+	}
+
+	d := differ{w: w, src: src, dst: dst, opts: o}
+	if o.DataOnly {
+		for _, tab := range dstSchema.Tables {
+			if old := findTable(srcSchema, tab.Name); old != nil && !tab.Virtual {
+				if err := d.data(old, &tab, false); err != nil {
+					return err
+				}
+			}
+		}
+		return d.err
+	}
+	return d.diff(srcSchema, dstSchema)
+}
+
+type differ struct {
+	w        io.Writer
+	src, dst *sqlite3.Conn
+	opts     Options
+	err      error
+}
+
+func (d *differ) diff(src, dst *sqlite3.Schema) error {
+	changed := map[string]bool{} // recreated, created or dropped tables
+	for _, tab := range src.Tables {
+		if new := findTable(dst, tab.Name); new == nil || !sameTable(&tab, new) {
+			changed[tab.Name] = true
+		}
+	}
+	for _, tab := range dst.Tables {
+		if findTable(src, tab.Name) == nil {
+			changed[tab.Name] = true
+		}
+	}
+
+	// Views and triggers may depend on changed tables:
+	// if any table changes, drop and recreate them all.
+	recreateAll := len(changed) > 0
+
+	for _, trg := range src.Triggers {
+		if new := findTrigger(dst, trg.Name); recreateAll || new == nil || new.SQL != trg.SQL {
+			d.printf("DROP TRIGGER %s;\n", id(trg.Name))
+		}
+	}
+	for _, view := range src.Views {
+		if new := findView(dst, view.Name); recreateAll || new == nil || new.SQL != view.SQL {
+			d.printf("DROP VIEW %s;\n", id(view.Name))
+		}
+	}
+	for _, tab := range src.Tables {
+		if changed[tab.Name] {
+			continue
+		}
+		new := findTable(dst, tab.Name)
+		for _, idx := range tab.Indexes {
+			if idx.SQL == "" {
+				continue
+			}
+			if nidx := findIndex(new, idx.Name); nidx == nil || nidx.SQL != idx.SQL {
+				d.printf("DROP INDEX %s;\n", id(idx.Name))
+			}
+		}
+	}
+
+	for _, tab := range src.Tables {
+		if findTable(dst, tab.Name) == nil {
+			d.printf("DROP TABLE %s;\n", id(tab.Name))
+		}
+	}
+	for i := range dst.Tables {
+		tab := &dst.Tables[i]
+		old := findTable(src, tab.Name)
+		switch {
+		case old == nil:
+			d.printf("%s;\n", tab.SQL)
+		case !sameTable(old, tab):
+			d.recreate(old, tab)
+		}
+		if !d.opts.SchemaOnly && !tab.Virtual {
+			if err := d.data(old, tab, old != nil && !sameTable(old, tab)); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, tab := range dst.Tables {
+		old := findTable(src, tab.Name)
+		for _, idx := range tab.Indexes {
+			if idx.SQL == "" {
+				continue
+			}
+			if changed[tab.Name] {
+				d.printf("%s;\n", idx.SQL)
+			} else if oidx := findIndex(old, idx.Name); oidx == nil || oidx.SQL != idx.SQL {
+				d.printf("%s;\n", idx.SQL)
+			}
+		}
+	}
+	for _, view := range dst.Views {
+		if old := findView(src, view.Name); recreateAll || old == nil || old.SQL != view.SQL {
+			d.printf("%s;\n", view.SQL)
+		}
+	}
+	for _, trg := range dst.Triggers {
+		if old := findTrigger(src, trg.Name); recreateAll || old == nil || old.SQL != trg.SQL {
+			d.printf("%s;\n", trg.SQL)
+		}
+	}
+	return d.err
+}
+
+// recreate changes the schema of a table
+// following the generalized ALTER TABLE procedure.
+func (d *differ) recreate(old, new *sqlite3.Table) {
+	if old.Virtual || new.Virtual {
+		d.printf("DROP TABLE %s;\n", id(old.Name))
+		d.printf("%s;\n", new.SQL)
+		return
+	}
+
+	tmp := "sqldiff_new_" + new.Name
+	sql, ok := renameTable(new.SQL, tmp)
+	if !ok {
+		d.printf("DROP TABLE %s;\n", id(old.Name))
+		d.printf("%s;\n", new.SQL)
+		return
+	}
+
+	var cols []string
+	if !old.WithoutRowID && !new.WithoutRowID {
+		cols = append(cols, "rowid")
+	}
+	for _, col := range commonColumns(old, new) {
+		cols = append(cols, id(col))
+	}
+
+	d.printf("%s;\n", sql)
+	if len(cols) > 0 {
+		list := strings.Join(cols, ",")
+		d.printf("INSERT INTO %s(%s) SELECT %s FROM %s;\n", id(tmp), list, list, id(old.Name))
+	}
+	d.printf("DROP TABLE %s;\n", id(old.Name))
+	d.printf("ALTER TABLE %s RENAME TO %s;\n", id(tmp), id(new.Name))
+}
+
+// data compares the rows of a table.
+// If old is nil, all rows of new are inserted.
+// If recreated is true, columns of new that are not in old are always updated.
+func (d *differ) data(old, new *sqlite3.Table, recreated bool) error {
+	keys := primaryKey(new)
+	cols := dataColumns(new)
+
+	var inSrc map[string]bool
+	if old != nil {
+		inSrc = map[string]bool{}
+		for _, col := range dataColumns(old) {
+			inSrc[col] = true
+		}
+		if keys == nil && old.WithoutRowID {
+			old = nil
+		}
+		for _, key := range keys {
+			if !inSrc[key] {
+				old = nil
+			}
+		}
+		if old == nil {
+			d.printf("DELETE FROM %s;\n", id(new.Name))
+		}
+	}
+	if keys == nil {
+		keys = []string{"rowid"}
+	}
+
+	var srcRows *rows
+	dstRows, err := d.query(d.dst, new.Name, keys, cols, nil)
+	if err != nil {
+		return err
+	}
+	defer dstRows.close()
+	if old != nil {
+		srcRows, err = d.query(d.src, new.Name, keys, cols, inSrc)
+		if err != nil {
+			return err
+		}
+		defer srcRows.close()
+	}
+
+	srcOK := srcRows.next()
+	dstOK := dstRows.next()
+	for srcOK || dstOK {
+		var c int
+		switch {
+		case !srcOK:
+			c = +1
+		case !dstOK:
+			c = -1
+		default:
+			c = compareKeys(srcRows.vals[:len(keys)], dstRows.vals[:len(keys)])
+		}
+
+		switch {
+		case c < 0:
+			d.printf("DELETE FROM %s WHERE %s;\n", id(new.Name), where(keys, srcRows.vals))
+			srcOK = srcRows.next()
+		case c > 0:
+			d.insert(new.Name, keys, cols, dstRows.vals)
+			dstOK = dstRows.next()
+		default:
+			var set []string
+			for i, col := range cols {
+				v := dstRows.vals[len(keys)+i]
+				if (recreated && !inSrc[col]) || !v.equal(srcRows.vals[len(keys)+i]) {
+					set = append(set, id(col)+"="+v.literal())
+				}
+			}
+			if len(set) > 0 {
+				d.printf("UPDATE %s SET %s WHERE %s;\n", id(new.Name),
+					strings.Join(set, ", "), where(keys, dstRows.vals))
+			}
+			srcOK = srcRows.next()
+			dstOK = dstRows.next()
+		}
+	}
+	if err := srcRows.error(); err != nil {
+		return err
+	}
+	return dstRows.error()
+}
+
+func (d *differ) insert(table string, keys, cols []string, vals []value) {
+	var names, lits []string
+	if keys[0] == "rowid" {
+		names = append(names, "rowid")
+		lits = append(lits, vals[0].literal())
+	}
+	for i, col := range cols {
+		names = append(names, id(col))
+		lits = append(lits, vals[len(keys)+i].literal())
+	}
+	d.printf("INSERT INTO %s(%s) VALUES(%s);\n", id(table),
+		strings.Join(names, ","), strings.Join(lits, ","))
+}
+
+func (d *differ) query(c *sqlite3.Conn, table string, keys, cols []string, has map[string]bool) (*rows, error) {
+	var sel, order []string
+	for _, key := range keys {
+		sel = append(sel, id(key))
+		order = append(order, id(key)+" COLLATE BINARY")
+	}
+	for _, col := range cols {
+		if has == nil || has[col] {
+			sel = append(sel, id(col))
+		} else {
+			sel = append(sel, "NULL")
+		}
+	}
+
+	stmt, _, err := c.Prepare(`SELECT ` + strings.Join(sel, ",") +
+		` FROM ` + id(d.opts.Schema) + `.` + id(table) +
+		` ORDER BY ` + strings.Join(order, ","))
+	if err != nil {
+		return nil, err
+	}
+	return &rows{stmt: stmt, vals: make([]value, len(sel))}, nil
+}
+
+func (d *differ) printf(format string, args ...any) {
+	if d.err == nil {
+		_, d.err = fmt.Fprintf(d.w, format, args...)
+	}
+}
+
+type rows struct {
+	stmt *sqlite3.Stmt
+	vals []value
+}
+
+func (r *rows) next() bool {
+	if r == nil || !r.stmt.Step() {
+		return false
+	}
+	for i := range r.vals {
+		r.vals[i] = columnValue(r.stmt, i)
+	}
+	return true
+}
+
+func (r *rows) error() error {
+	if r == nil {
+		return nil
+	}
+	return r.stmt.Err()
+}
+
+func (r *rows) close() error {
+	return r.stmt.Close()
+}
+
+type value struct {
+	typ sqlite3.Datatype
+	i   int64
+	f   float64
+	b   string
+}
+
+func columnValue(stmt *sqlite3.Stmt, col int) value {
+	v := value{typ: stmt.ColumnType(col)}
+	switch v.typ {
+	case sqlite3.INTEGER:
+		v.i = stmt.ColumnInt64(col)
+	case sqlite3.FLOAT:
+		v.f = stmt.ColumnFloat(col)
+	case sqlite3.TEXT:
+		v.b = stmt.ColumnText(col)
+	case sqlite3.BLOB:
+		v.b = string(stmt.ColumnRawBlob(col))
+	}
+	return v
+}
+
+func (v value) equal(w value) bool {
+	return v.typ == w.typ && v.i == w.i && v.b == w.b &&
+		(v.f == w.f || math.IsNaN(v.f) && math.IsNaN(w.f))
+}
+
+func (v value) literal() string {
+	switch v.typ {
+	case sqlite3.INTEGER:
+		return strconv.FormatInt(v.i, 10)
+	case sqlite3.FLOAT:
+		s := sqlite3.Quote(v.f)
+		if !strings.ContainsAny(s, ".eEN") {
+			s += ".0"
+		}
+		return s
+	case sqlite3.TEXT:
+		if strings.IndexByte(v.b, 0) >= 0 {
+			return "CAST(" + sqlite3.Quote([]byte(v.b)) + " AS TEXT)"
+		}
+		return sqlite3.Quote(v.b)
+	case sqlite3.BLOB:
+		return sqlite3.Quote([]byte(v.b))
+	}
+	return "NULL"
+}
+
+// compareKeys compares keys with the BINARY collation.
+func compareKeys(a, b []value) int {
+	for i := range a {
+		if c := compareValues(a[i], b[i]); c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+func compareValues(a, b value) int {
+	class := func(v value) int {
+		switch v.typ {
+		case sqlite3.NULL:
+			return 0
+		case sqlite3.INTEGER, sqlite3.FLOAT:
+			return 1
+		case sqlite3.TEXT:
+			return 2
+		}
+		return 3
+	}
+
+	ca, cb := class(a), class(b)
+	switch {
+	case ca != cb:
+		return ca - cb
+	case ca == 0:
+		return 0
+	case ca == 1:
+		if a.typ == sqlite3.INTEGER && b.typ == sqlite3.INTEGER {
+			return cmp(a.i, b.i)
+		}
+		fa, fb := a.f, b.f
+		if a.typ == sqlite3.INTEGER {
+			fa = float64(a.i)
+		}
+		if b.typ == sqlite3.INTEGER {
+			fb = float64(b.i)
+		}
+		return cmp(fa, fb)
+	}
+	return strings.Compare(a.b, b.b)
+}
+
+func cmp[T int64 | float64](a, b T) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return +1
+	}
+	return 0
+}
+
+func where(keys []string, vals []value) string {
+	var conds []string
+	for i, key := range keys {
+		if vals[i].typ == sqlite3.NULL {
+			conds = append(conds, id(key)+" IS NULL")
+		} else {
+			conds = append(conds, id(key)+"="+vals[i].literal())
+		}
+	}
+	return strings.Join(conds, " AND ")
+}
+
+func primaryKey(tab *sqlite3.Table) []string {
+	var keys []string
+	for pk := 1; ; pk++ {
+		found := false
+		for _, col := range tab.Columns {
+			if col.PrimaryKey == pk {
+				keys = append(keys, col.Name)
+				found = true
+			}
+		}
+		if !found {
+			return keys
+		}
+	}
+}
+
+func dataColumns(tab *sqlite3.Table) []string {
+	var cols []string
+	for _, col := range tab.Columns {
+		if col.Generated == "" && !col.Hidden {
+			cols = append(cols, col.Name)
+		}
+	}
+	return cols
+}
+
+func commonColumns(old, new *sqlite3.Table) []string {
+	has := map[string]bool{}
+	for _, col := range dataColumns(old) {
+		has[col] = true
+	}
+	var cols []string
+	for _, col := range dataColumns(new) {
+		if has[col] {
+			cols = append(cols, col)
+		}
+	}
+	return cols
+}
+
+func findTable(s *sqlite3.Schema, name string) *sqlite3.Table {
+	for i := range s.Tables {
+		if strings.EqualFold(s.Tables[i].Name, name) {
+			return &s.Tables[i]
+		}
+	}
+	return nil
+}
+
+func findView(s *sqlite3.Schema, name string) *sqlite3.View {
+	for i := range s.Views {
+		if strings.EqualFold(s.Views[i].Name, name) {
+			return &s.Views[i]
+		}
+	}
+	return nil
+}
+
+func findTrigger(s *sqlite3.Schema, name string) *sqlite3.Trigger {
+	for i := range s.Triggers {
+		if strings.EqualFold(s.Triggers[i].Name, name) {
+			return &s.Triggers[i]
+		}
+	}
+	return nil
+}
+
+func findIndex(t *sqlite3.Table, name string) *sqlite3.Index {
+	if t == nil {
+		return nil
+	}
+	for i := range t.Indexes {
+		if strings.EqualFold(t.Indexes[i].Name, name) {
+			return &t.Indexes[i]
+		}
+	}
+	return nil
+}
+
+func id(name string) string {
+	if name == "rowid" {
+		return name
+	}
+	return sqlite3.QuoteIdentifier(name)
+}
+
+// renameTable replaces the table name in a CREATE TABLE statement.
+func renameTable(sql, name string) (string, bool) {
+	var start, end int
+	pos := 0
+	next := func() string {
+		start, end = token(sql, pos)
+		pos = end
+		return strings.ToUpper(sql[start:end])
+	}
+
+	if next() != "CREATE" {
+		return "", false
+	}
+	tok := next()
+	if tok == "TEMP" || tok == "TEMPORARY" {
+		tok = next()
+	}
+	if tok != "TABLE" {
+		return "", false
+	}
+	tok = next()
+	if tok == "IF" {
+		if next() != "NOT" || next() != "EXISTS" {
+			return "", false
+		}
+		next()
+	}
+	first, last := start, end
+	if next() == "." {
+		next()
+		last = end
+	}
+	if first == last {
+		return "", false
+	}
+	return sql[:first] + id(name) + sql[last:], true
+}
+
+// token returns the bounds of the next token in sql,
+// skipping whitespace and comments.
+func token(sql string, i int) (start, end int) {
+loop:
+	for i < len(sql) {
+		switch {
+		case strings.IndexByte(" \t\n\f\r", sql[i]) >= 0:
+			i++
+		case strings.HasPrefix(sql[i:], "--"):
+			if n := strings.IndexByte(sql[i:], '\n'); n >= 0 {
+				i += n + 1
+			} else {
+				i = len(sql)
+			}
+		case strings.HasPrefix(sql[i:], "/*"):
+			if n := strings.Index(sql[i+2:], "*/"); n >= 0 {
+				i += n + 4
+			} else {
+				i = len(sql)
+			}
+		default:
+			break loop
+		}
+	}
+	if i == len(sql) {
+		return i, i
+	}
+
+	start = i
+	switch q := sql[i]; q {
+	case '"', '\'', '`', '[':
+		if q == '[' {
+			q = ']'
+		}
+		for i++; i < len(sql); i++ {
+			if sql[i] == q {
+				if i+1 < len(sql) && sql[i+1] == q && q != ']' {
+					i++
+					continue
+				}
+				return start, i + 1
+			}
+		}
+		return start, len(sql)
+	}
+	for i < len(sql) && (sql[i] == '_' || sql[i] >= 0x80 ||
+		'a' <= sql[i]|0x20 && sql[i]|0x20 <= 'z' || '0' <= sql[i] && sql[i] <= '9' || sql[i] == '$') {
+		i++
+	}
+	if i == start {
+		i++
+	}
+	return start, i
+}
+
+// sameTable compares table definitions,
+// ignoring how the table name is quoted.
+func sameTable(a, b *sqlite3.Table) bool {
+	as, aok := renameTable(a.SQL, a.Name)
+	bs, bok := renameTable(b.SQL, b.Name)
+	if aok && bok {
+		return as == bs
+	}
+	return a.SQL == b.SQL
+}
@@ -0,0 +1,110 @@
+package sqldiff_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ncruces/go-sqlite3"
+	_ "github.com/ncruces/go-sqlite3/embed"
+	"github.com/ncruces/go-sqlite3/sqldiff"
+)
+
+func TestDiff(t *testing.T) {
+	t.Parallel()
+
+	src := open(t, `
+		CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT);
+		CREATE TABLE logs (msg);
+		CREATE TABLE kv (k, v, PRIMARY KEY (k)) WITHOUT ROWID;
+		CREATE TABLE old (x);
+		CREATE INDEX users_name ON users (name);
+		CREATE VIEW names AS SELECT name FROM users;
+		INSERT INTO users VALUES (1, 'alice'), (2, 'bob'), (3, 'carol');
+		INSERT INTO logs VALUES ('a'), ('b');
+		INSERT INTO kv VALUES (1, 1.0), ('a', x'00'), (x'01', NULL);
+	`)
+	dst := open(t, `
+		CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT NOT NULL, email TEXT);
+		CREATE TABLE logs (msg);
+		CREATE TABLE kv (k, v, PRIMARY KEY (k)) WITHOUT ROWID;
+		CREATE TABLE "new table" (y DEFAULT 'y');
+		CREATE INDEX users_email ON users (email);
+		CREATE VIEW names AS SELECT name, email FROM users;
+		CREATE TRIGGER logs_insert AFTER INSERT ON logs BEGIN SELECT 1; END;
+		INSERT INTO users VALUES (1, 'alice', 'a@x'), (3, 'Carol', NULL), (4, 'dan', 'd@x');
+		INSERT INTO logs VALUES ('a'), ('c');
+		INSERT INTO kv VALUES (1, 1), ('a', 'a'||char(0)||'b'), (2.5, 1e100);
+		INSERT INTO "new table" VALUES ('y');
+	`)
+
+	var buf strings.Builder
+	err := sqldiff.Diff(&buf, src, dst, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = src.Exec(`BEGIN; PRAGMA defer_foreign_keys=1;` + buf.String() + `COMMIT;`)
+	if err != nil {
+		t.Fatal(err, buf.String())
+	}
+
+	// After applying the script there are no differences.
+	buf.Reset()
+	err = sqldiff.Diff(&buf, src, dst, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("got %q", buf.String())
+	}
+}
+
+func TestDiff_options(t *testing.T) {
+	t.Parallel()
+
+	src := open(t, `
+		CREATE TABLE t (x);
+		INSERT INTO t VALUES (1);
+	`)
+	dst := open(t, `
+		CREATE TABLE t (x);
+		CREATE TABLE u (y);
+		INSERT INTO t VALUES (2);
+	`)
+
+	var buf strings.Builder
+	err := sqldiff.Diff(&buf, src, dst, &sqldiff.Options{SchemaOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); got != "CREATE TABLE u (y);\n" {
+		t.Errorf("got %q", got)
+	}
+
+	buf.Reset()
+	err = sqldiff.Diff(&buf, src, dst, &sqldiff.Options{DataOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); got != "UPDATE \"t\" SET \"x\"=2 WHERE rowid=1;\n" {
+		t.Errorf("got %q", got)
+	}
+
+	if err := sqldiff.Diff(&buf, src, dst, &sqldiff.Options{Schema: "missing"}); err == nil {
+		t.Error("want error")
+	}
+}
+
+func open(t testing.TB, sql string) *sqlite3.Conn {
+	db, err := sqlite3.Open(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	err = db.Exec(sql)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
@@ -153,6 +153,15 @@ func (sqlt *sqlite) error(rc uint64, handle uint32, sql ...string) error {
 	return &err
 }
 
+// exports checks that the SQLite binary exports name;
+// binaries built without an optional feature don't.
+func (sqlt *sqlite) exports(name string) error {
+	if sqlt.mod.ExportedFunction(name) == nil {
+		return util.NoExportErr + util.ErrorString(name)
+	}
+	return nil
+}
+
 func (sqlt *sqlite) getfn(name string) api.Function {
 	c := &sqlt.funcs
 	p := unsafe.StringData(name)
@@ -184,6 +193,9 @@ func (sqlt *sqlite) putfn(name string, fn api.Function) {
 func (sqlt *sqlite) call(name string, params ...uint64) uint64 {
 	copy(sqlt.stack[:], params)
 	fn := sqlt.getfn(name)
+	if fn == nil {
+		panic(util.NoExportErr + util.ErrorString(name))
+	}
 	err := fn.CallWithStack(sqlt.ctx, sqlt.stack[:])
 	if err != nil {
 		panic(err)
@@ -340,5 +352,10 @@ func exportCallbacks(env wazero.HostModuleBuilder) wazero.HostModuleBuilder {
 	util.ExportFuncII(env, "go_cur_eof", cursorEOFCallback)
 	util.ExportFuncIIII(env, "go_cur_column", cursorColumnCallback)
 	util.ExportFuncIII(env, "go_cur_rowid", cursorRowIDCallback)
+	util.ExportFuncIIIII(env, "go_fts5_create", fts5CreateCallback)
+	util.ExportFuncIIIIII(env, "go_fts5_tokenize", fts5TokenizeCallback)
+	util.ExportFuncVIIIIII(env, "go_fts5_aux", fts5AuxCallback)
+	util.ExportFuncIII(env, "go_rtree_query", rtreeQueryCallback)
+	util.ExportFuncIII(env, "go_recover_sql", recoverSQLCallback)
 	return env
 }
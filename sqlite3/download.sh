@@ -19,6 +19,9 @@ curl -#OL "https://github.com/sqlite/sqlite/raw/version-3.46.0/ext/misc/ieee754.
 curl -#OL "https://github.com/sqlite/sqlite/raw/version-3.46.0/ext/misc/regexp.c"
 curl -#OL "https://github.com/sqlite/sqlite/raw/version-3.46.0/ext/misc/series.c"
 curl -#OL "https://github.com/sqlite/sqlite/raw/version-3.46.0/ext/misc/uint.c"
+curl -#OL "https://github.com/sqlite/sqlite/raw/version-3.46.0/ext/recover/dbdata.c"
+curl -#OL "https://github.com/sqlite/sqlite/raw/version-3.46.0/ext/recover/sqlite3recover.c"
+curl -#OL "https://github.com/sqlite/sqlite/raw/version-3.46.0/ext/recover/sqlite3recover.h"
 cd ~-
 
 cd ../vfs/tests/mptest/testdata/
@@ -0,0 +1,144 @@
+#include <stddef.h>
+
+#include "include.h"
+#include "sqlite3.h"
+
+struct go_fts5_token {
+  void *pCtx;
+  int (*xToken)(void *, int, const char *, int, int, int);
+};
+
+int go_fts5_create(go_handle, const char **azArg, int nArg, go_handle *ppOut);
+int go_fts5_tokenize(go_handle, struct go_fts5_token *, int flags,
+                     const char *pText, int nText);
+
+static int go_fts5_create_wrapper(void *pApp, const char **azArg, int nArg,
+                                  Fts5Tokenizer **ppOut) {
+  return go_fts5_create(pApp, azArg, nArg, (go_handle *)ppOut);
+}
+
+static void go_fts5_delete_wrapper(Fts5Tokenizer *pTok) { go_destroy(pTok); }
+
+static int go_fts5_tokenize_wrapper(
+    Fts5Tokenizer *pTok, void *pCtx, int flags, const char *pText, int nText,
+    int (*xToken)(void *, int, const char *, int, int, int)) {
+  struct go_fts5_token token = {pCtx, xToken};
+  return go_fts5_tokenize(pTok, &token, flags, pText, nText);
+}
+
+int sqlite3_fts5_token_go(struct go_fts5_token *token, int tflags,
+                          const char *pToken, int nToken, int iStart,
+                          int iEnd) {
+  return token->xToken(token->pCtx, tflags, pToken, nToken, iStart, iEnd);
+}
+
+static fts5_api *go_fts5_api(sqlite3 *db) {
+  fts5_api *api = NULL;
+  sqlite3_stmt *stmt = NULL;
+  if (sqlite3_prepare_v2(db, "SELECT fts5(?1)", -1, &stmt, NULL) == SQLITE_OK) {
+    sqlite3_bind_pointer(stmt, 1, &api, "fts5_api_ptr", NULL);
+    sqlite3_step(stmt);
+  }
+  sqlite3_finalize(stmt);
+  return api;
+}
+
+int sqlite3_create_tokenizer_go(sqlite3 *db, const char *name, go_handle app) {
+  fts5_api *api = go_fts5_api(db);
+  if (api == NULL) {
+    go_destroy(app);
+    return SQLITE_ERROR;
+  }
+
+  fts5_tokenizer tokenizer = {
+      .xCreate = go_fts5_create_wrapper,
+      .xDelete = go_fts5_delete_wrapper,
+      .xTokenize = go_fts5_tokenize_wrapper,
+  };
+  int rc = api->xCreateTokenizer(api, name, app, &tokenizer, go_destroy);
+  if (rc) go_destroy(app);
+  return rc;
+}
+
+void go_fts5_aux(go_handle, const Fts5ExtensionApi *, Fts5Context *,
+                 sqlite3_context *, int nVal, sqlite3_value **apVal);
+
+static void go_fts5_aux_wrapper(const Fts5ExtensionApi *pApi,
+                                Fts5Context *pFts, sqlite3_context *pCtx,
+                                int nVal, sqlite3_value **apVal) {
+  go_fts5_aux(pApi->xUserData(pFts), pApi, pFts, pCtx, nVal, apVal);
+}
+
+int sqlite3_create_fts5_function_go(sqlite3 *db, const char *name,
+                                    go_handle app) {
+  fts5_api *api = go_fts5_api(db);
+  if (api == NULL) {
+    go_destroy(app);
+    return SQLITE_ERROR;
+  }
+
+  int rc = api->xCreateFunction(api, name, app, go_fts5_aux_wrapper, go_destroy);
+  if (rc) go_destroy(app);
+  return rc;
+}
+
+int sqlite3_fts5_column_count_go(const Fts5ExtensionApi *api,
+                                 Fts5Context *fts) {
+  return api->xColumnCount(fts);
+}
+
+int sqlite3_fts5_row_count_go(const Fts5ExtensionApi *api, Fts5Context *fts,
+                              sqlite3_int64 *pnRow) {
+  return api->xRowCount(fts, pnRow);
+}
+
+int sqlite3_fts5_column_total_size_go(const Fts5ExtensionApi *api,
+                                      Fts5Context *fts, int iCol,
+                                      sqlite3_int64 *pnToken) {
+  return api->xColumnTotalSize(fts, iCol, pnToken);
+}
+
+int sqlite3_fts5_phrase_count_go(const Fts5ExtensionApi *api,
+                                 Fts5Context *fts) {
+  return api->xPhraseCount(fts);
+}
+
+int sqlite3_fts5_phrase_size_go(const Fts5ExtensionApi *api, Fts5Context *fts,
+                                int iPhrase) {
+  return api->xPhraseSize(fts, iPhrase);
+}
+
+int sqlite3_fts5_inst_count_go(const Fts5ExtensionApi *api, Fts5Context *fts,
+                               int *pnInst) {
+  return api->xInstCount(fts, pnInst);
+}
+
+int sqlite3_fts5_inst_go(const Fts5ExtensionApi *api, Fts5Context *fts,
+                         int iIdx, int *piPhrase, int *piCol, int *piOff) {
+  return api->xInst(fts, iIdx, piPhrase, piCol, piOff);
+}
+
+sqlite3_int64 sqlite3_fts5_rowid_go(const Fts5ExtensionApi *api,
+                                    Fts5Context *fts) {
+  return api->xRowid(fts);
+}
+
+int sqlite3_fts5_column_text_go(const Fts5ExtensionApi *api, Fts5Context *fts,
+                                int iCol, const char **pz, int *pn) {
+  return api->xColumnText(fts, iCol, pz, pn);
+}
+
+int sqlite3_fts5_column_size_go(const Fts5ExtensionApi *api, Fts5Context *fts,
+                                int iCol, int *pnToken) {
+  return api->xColumnSize(fts, iCol, pnToken);
+}
+
+int sqlite3_fts5_set_auxdata_go(const Fts5ExtensionApi *api, Fts5Context *fts,
+                                go_handle aux) {
+  return api->xSetAuxdata(fts, aux, go_destroy);
+}
+
+go_handle sqlite3_fts5_get_auxdata_go(const Fts5ExtensionApi *api,
+                                      Fts5Context *fts, int bClear) {
+  return api->xGetAuxdata(fts, bClear);
+}
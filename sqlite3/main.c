@@ -8,11 +8,16 @@
 #include "ext/regexp.c"
 #include "ext/series.c"
 #include "ext/uint.c"
+#include "ext/dbdata.c"
+#include "ext/sqlite3recover.c"
 // Bindings
 #include "column.c"
+#include "fts5.c"
 #include "func.c"
 #include "hooks.c"
 #include "pointer.c"
+#include "recover.c"
+#include "rtree.c"
 #include "time.c"
 #include "vfs.c"
 #include "vtab.c"
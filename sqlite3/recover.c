@@ -0,0 +1,10 @@
+#include "include.h"
+#include "sqlite3.h"
+#include "ext/sqlite3recover.h"
+
+int go_recover_sql(go_handle, const char *);
+
+sqlite3_recover *sqlite3_recover_init_sql_go(sqlite3 *db, const char *zDb,
+                                             go_handle app) {
+  return sqlite3_recover_init_sql(db, zDb, go_recover_sql, app);
+}
@@ -0,0 +1,28 @@
+#include <stddef.h>
+
+#include "include.h"
+#include "sqlite3.h"
+
+int go_rtree_query(go_handle, sqlite3_rtree_query_info *);
+
+static int go_rtree_query_wrapper(sqlite3_rtree_query_info *info) {
+  return go_rtree_query(info->pContext, info);
+}
+
+int sqlite3_rtree_query_callback_go(sqlite3 *db, const char *name,
+                                    go_handle app) {
+  return sqlite3_rtree_query_callback(db, name, go_rtree_query_wrapper, app,
+                                      go_destroy);
+}
+
+static_assert(offsetof(sqlite3_rtree_query_info, nParam) == 4, "Unexpected offset");
+static_assert(offsetof(sqlite3_rtree_query_info, aParam) == 8, "Unexpected offset");
+static_assert(offsetof(sqlite3_rtree_query_info, aCoord) == 20, "Unexpected offset");
+static_assert(offsetof(sqlite3_rtree_query_info, nCoord) == 28, "Unexpected offset");
+static_assert(offsetof(sqlite3_rtree_query_info, iLevel) == 32, "Unexpected offset");
+static_assert(offsetof(sqlite3_rtree_query_info, mxLevel) == 36, "Unexpected offset");
+static_assert(offsetof(sqlite3_rtree_query_info, iRowid) == 40, "Unexpected offset");
+static_assert(offsetof(sqlite3_rtree_query_info, rParentScore) == 48, "Unexpected offset");
+static_assert(offsetof(sqlite3_rtree_query_info, eParentWithin) == 56, "Unexpected offset");
+static_assert(offsetof(sqlite3_rtree_query_info, eWithin) == 60, "Unexpected offset");
+static_assert(offsetof(sqlite3_rtree_query_info, rScore) == 64, "Unexpected offset");
@@ -39,6 +39,8 @@
 #define SQLITE_ENABLE_FTS5 1
 #define SQLITE_ENABLE_RTREE 1
 #define SQLITE_ENABLE_GEOPOLY 1
+#define SQLITE_ENABLE_DBPAGE_VTAB 1
+#define SQLITE_ENABLE_DBSTAT_VTAB 1
 
 #define SQLITE_SOUNDEX
 #define SQLITE_UNTESTABLE
\ No newline at end of file
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/ncruces/go-sqlite3/internal/util"
+	"github.com/ncruces/go-sqlite3/util/jsonb"
 )
 
 // Stmt is a prepared statement object.
@@ -54,9 +55,14 @@ func (s *Stmt) ReadOnly() bool {
 //
 // https://sqlite.org/c3ref/reset.html
 func (s *Stmt) Reset() error {
+	busy := s.c.stmt != nil && s != s.c.pending && s.Busy()
 	r := s.c.call("sqlite3_reset", uint64(s.handle))
 	s.err = nil
-	return s.c.error(r)
+	err := s.c.error(r)
+	if busy {
+		s.c.stmt(err)
+	}
+	return err
 }
 
 // Busy determines if a prepared statement has been reset.
@@ -88,6 +94,9 @@ func (s *Stmt) Step() bool {
 	default:
 		s.err = s.c.error(r)
 	}
+	if s.c.stmt != nil && s != s.c.pending {
+		s.c.stmt(s.err)
+	}
 	return false
 }
 
@@ -338,6 +347,18 @@ func (s *Stmt) BindJSON(param int, value any) error {
 	return s.BindRawText(param, data)
 }
 
+// BindJSONB binds the [JSONB] encoding of value to the prepared statement.
+// The leftmost SQL parameter has an index of 1.
+//
+// [JSONB]: https://sqlite.org/jsonb.html
+func (s *Stmt) BindJSONB(param int, value any) error {
+	data, err := jsonb.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return s.BindBlob(param, data)
+}
+
 // BindValue binds a copy of value to the prepared statement.
 // The leftmost SQL parameter has an index of 1.
 //
@@ -579,6 +600,20 @@ func (s *Stmt) ColumnJSON(col int, ptr any) error {
 	return json.Unmarshal(data, ptr)
 }
 
+// ColumnJSONB parses the value of the result column,
+// which must be a [JSONB] blob,
+// and stores it in the value pointed to by ptr.
+// Values other than BLOBs are parsed as with [Stmt.ColumnJSON].
+// The leftmost column of the result set has the index 0.
+//
+// [JSONB]: https://sqlite.org/jsonb.html
+func (s *Stmt) ColumnJSONB(col int, ptr any) error {
+	if s.ColumnType(col) == BLOB {
+		return jsonb.Unmarshal(s.ColumnRawBlob(col), ptr)
+	}
+	return s.ColumnJSON(col, ptr)
+}
+
 // ColumnValue returns the unprotected value of the result column.
 // The leftmost column of the result set has the index 0.
 //
@@ -0,0 +1,390 @@
+package sqlite3
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/ncruces/go-sqlite3/vfs"
+)
+
+// BackupTo writes a consistent snapshot of the schema database
+// on the c connection to w, as an SQLite database file.
+//
+// The snapshot is created with [VACUUM INTO] a private VFS,
+// which keeps it in memory, outside the page cache,
+// until it's complete, and then writes it to w in page order.
+// To compress the stream, wrap w with a compressor, like [compress/gzip].
+// If ctx is canceled, the backup is stopped, and ctx.Err() is returned.
+//
+// BackupTo can't be called in a transaction.
+//
+// [VACUUM INTO]: https://sqlite.org/lang_vacuum.html#vacuuminto
+func (c *Conn) BackupTo(ctx context.Context, schema string, w io.Writer) error {
+	if schema == "" {
+		schema = "main"
+	}
+
+	old := c.SetInterrupt(ctx)
+	defer c.SetInterrupt(old)
+
+	f := &streamWriter{}
+	name := streamRegister(f)
+	defer streamUnregister(name)
+
+	err := c.Exec(`VACUUM ` + QuoteIdentifier(schema) +
+		` INTO ` + Quote("file:"+name+"?vfs="+streamVFSName))
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		return err
+	}
+
+	for i, page := range f.pages {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if i == 0 {
+			// The snapshot is not in WAL mode.
+			legacyFileFormat(page)
+		}
+		if _, err := w.Write(page); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RestoreFrom restores the schema database on the c connection
+// from an SQLite database file read from r.
+//
+// Pages are streamed in order, without buffering the database.
+// To decompress the stream, wrap r with a decompressor, like [compress/gzip].
+// If ctx is canceled, the restore is stopped, and ctx.Err() is returned.
+func (c *Conn) RestoreFrom(ctx context.Context, schema string, r io.Reader) error {
+	if schema == "" {
+		schema = "main"
+	}
+
+	name := streamRegister(r)
+	defer streamUnregister(name)
+
+	b, err := c.restoreInit(schema, "file:"+name+"?vfs="+streamVFSName)
+	if err != nil {
+		return err
+	}
+	return b.run(ctx, nil)
+}
+
+// legacyFileFormat sets the file format version numbers
+// of a database header to rollback journal mode.
+func legacyFileFormat(header []byte) {
+	if len(header) >= 20 && header[18] == 2 && header[19] == 2 {
+		header[18] = 1
+		header[19] = 1
+	}
+}
+
+const streamVFSName = "sqlite3_stream"
+
+var (
+	streamOnce  sync.Once
+	streamMtx   sync.Mutex
+	streamCount atomic.Uint64
+	streamFiles = map[string]any{}
+)
+
+// streamRegister registers an io.Reader to restore from,
+// or a *streamWriter to back up to.
+func streamRegister(f any) string {
+	streamOnce.Do(func() {
+		vfs.Register(streamVFSName, streamVFS{})
+	})
+	name := "/stream-" + strconv.FormatUint(streamCount.Add(1), 10) + ".db"
+	streamMtx.Lock()
+	streamFiles[name] = f
+	streamMtx.Unlock()
+	return name
+}
+
+func streamUnregister(name string) {
+	streamMtx.Lock()
+	delete(streamFiles, name)
+	streamMtx.Unlock()
+}
+
+// streamVFS serves a database file from an io.Reader,
+// provided it is read in order, which the backup API does,
+// or in a *streamWriter.
+type streamVFS struct{}
+
+func (streamVFS) Open(name string, flags vfs.OpenFlag) (vfs.File, vfs.OpenFlag, error) {
+	if flags&vfs.OPEN_MAIN_JOURNAL != 0 {
+		// The copy is new, so its journal is never needed.
+		return streamJournal{}, flags, nil
+	}
+	if flags&vfs.OPEN_MAIN_DB == 0 {
+		return nil, flags, CANTOPEN
+	}
+
+	streamMtx.Lock()
+	f, ok := streamFiles[name]
+	delete(streamFiles, name)
+	streamMtx.Unlock()
+	if !ok {
+		return nil, flags, CANTOPEN
+	}
+	if w, ok := f.(*streamWriter); ok {
+		return w, flags, nil
+	}
+	r := f.(io.Reader)
+
+	// Read the header to find the page and database sizes.
+	var header [100]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, flags, NOTADB
+	}
+	pageSize := int64(binary.BigEndian.Uint16(header[16:]))
+	if pageSize == 1 {
+		pageSize = 65536
+	}
+	pageCount := int64(binary.BigEndian.Uint32(header[28:]))
+	if pageSize < 512 || pageSize&(pageSize-1) != 0 || pageCount == 0 ||
+		binary.BigEndian.Uint32(header[24:]) != binary.BigEndian.Uint32(header[92:]) {
+		return nil, flags, NOTADB
+	}
+
+	// Keep the first page, which is read more than once.
+	first := make([]byte, pageSize)
+	copy(first, header[:])
+	if _, err := io.ReadFull(r, first[len(header):]); err != nil {
+		return nil, flags, NOTADB
+	}
+	legacyFileFormat(first)
+
+	return &streamFile{
+		r:     r,
+		first: first,
+		pos:   pageSize,
+		size:  pageSize * pageCount,
+	}, flags | vfs.OPEN_READONLY, nil
+}
+
+func (streamVFS) Delete(name string, dirSync bool) error {
+	if strings.HasSuffix(name, "-journal") {
+		return nil
+	}
+	return IOERR_DELETE
+}
+
+func (streamVFS) Access(name string, flag vfs.AccessFlag) (bool, error) {
+	return false, nil
+}
+
+func (streamVFS) FullPathname(name string) (string, error) {
+	return name, nil
+}
+
+type streamFile struct {
+	r     io.Reader
+	first []byte
+	pos   int64
+	size  int64
+}
+
+func (f *streamFile) ReadAt(p []byte, off int64) (n int, err error) {
+	if off+int64(len(p)) <= int64(len(f.first)) {
+		return copy(p, f.first[off:]), nil
+	}
+	if off < f.pos {
+		// The stream can't be rewound.
+		return 0, IOERR_READ
+	}
+	if off > f.pos {
+		n, err := io.CopyN(io.Discard, f.r, off-f.pos)
+		f.pos += n
+		if err != nil {
+			return 0, err
+		}
+	}
+	n, err = io.ReadFull(f.r, p)
+	f.pos += int64(n)
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+	return n, err
+}
+
+func (f *streamFile) Size() (int64, error) {
+	return f.size, nil
+}
+
+func (f *streamFile) Close() error {
+	return nil
+}
+
+func (f *streamFile) WriteAt(b []byte, off int64) (n int, err error) {
+	return 0, READONLY
+}
+
+func (f *streamFile) Truncate(size int64) error {
+	return READONLY
+}
+
+func (f *streamFile) Sync(flag vfs.SyncFlag) error {
+	return nil
+}
+
+func (f *streamFile) Lock(lock vfs.LockLevel) error {
+	return nil
+}
+
+func (f *streamFile) Unlock(lock vfs.LockLevel) error {
+	return nil
+}
+
+func (f *streamFile) CheckReservedLock() (bool, error) {
+	return false, nil
+}
+
+func (f *streamFile) SectorSize() int {
+	return 0
+}
+
+func (f *streamFile) DeviceCharacteristics() vfs.DeviceCharacteristic {
+	return vfs.IOCAP_IMMUTABLE
+}
+
+// streamWriter holds the pages of a database file
+// created by VACUUM INTO, until it's written out.
+type streamWriter struct {
+	pages [][]byte
+}
+
+func (f *streamWriter) ReadAt(p []byte, off int64) (n int, err error) {
+	for n < len(p) {
+		if len(f.pages) == 0 {
+			return n, io.EOF
+		}
+		size := int64(len(f.pages[0]))
+		i := (off + int64(n)) / size
+		if i >= int64(len(f.pages)) {
+			return n, io.EOF
+		}
+		n += copy(p[n:], f.pages[i][(off+int64(n))%size:])
+	}
+	return n, nil
+}
+
+func (f *streamWriter) WriteAt(p []byte, off int64) (n int, err error) {
+	size := int64(len(p))
+	if len(f.pages) > 0 {
+		size = int64(len(f.pages[0]))
+	}
+	if int64(len(p)) != size || off%size != 0 {
+		// Only whole pages are written.
+		return 0, IOERR_WRITE
+	}
+	i := off / size
+	for int64(len(f.pages)) <= i {
+		f.pages = append(f.pages, make([]byte, size))
+	}
+	return copy(f.pages[i], p), nil
+}
+
+func (f *streamWriter) Size() (int64, error) {
+	if len(f.pages) == 0 {
+		return 0, nil
+	}
+	return int64(len(f.pages) * len(f.pages[0])), nil
+}
+
+func (f *streamWriter) Close() error {
+	return nil
+}
+
+func (f *streamWriter) Truncate(size int64) error {
+	if len(f.pages) > 0 {
+		n := size / int64(len(f.pages[0]))
+		if n < int64(len(f.pages)) {
+			f.pages = f.pages[:n]
+		}
+	}
+	return nil
+}
+
+func (f *streamWriter) Sync(flag vfs.SyncFlag) error {
+	return nil
+}
+
+func (f *streamWriter) Lock(lock vfs.LockLevel) error {
+	return nil
+}
+
+func (f *streamWriter) Unlock(lock vfs.LockLevel) error {
+	return nil
+}
+
+func (f *streamWriter) CheckReservedLock() (bool, error) {
+	return false, nil
+}
+
+func (f *streamWriter) SectorSize() int {
+	return 0
+}
+
+func (f *streamWriter) DeviceCharacteristics() vfs.DeviceCharacteristic {
+	return 0
+}
+
+// streamJournal discards the journal of a *streamWriter.
+type streamJournal struct{}
+
+func (streamJournal) ReadAt(p []byte, off int64) (n int, err error) {
+	return 0, io.EOF
+}
+
+func (streamJournal) WriteAt(p []byte, off int64) (n int, err error) {
+	return len(p), nil
+}
+
+func (streamJournal) Size() (int64, error) {
+	return 0, nil
+}
+
+func (streamJournal) Close() error {
+	return nil
+}
+
+func (streamJournal) Truncate(size int64) error {
+	return nil
+}
+
+func (streamJournal) Sync(flag vfs.SyncFlag) error {
+	return nil
+}
+
+func (streamJournal) Lock(lock vfs.LockLevel) error {
+	return nil
+}
+
+func (streamJournal) Unlock(lock vfs.LockLevel) error {
+	return nil
+}
+
+func (streamJournal) CheckReservedLock() (bool, error) {
+	return false, nil
+}
+
+func (streamJournal) SectorSize() int {
+	return 0
+}
+
+func (streamJournal) DeviceCharacteristics() vfs.DeviceCharacteristic {
+	return 0
+}
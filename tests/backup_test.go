@@ -1,13 +1,16 @@
 package tests
 
 import (
+	"context"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/ncruces/go-sqlite3"
 	_ "github.com/ncruces/go-sqlite3/embed"
 	_ "github.com/ncruces/go-sqlite3/internal/testcfg"
 	"github.com/ncruces/go-sqlite3/vfs"
+	_ "github.com/ncruces/go-sqlite3/vfs/memdb"
 )
 
 func TestBackup(t *testing.T) {
@@ -172,3 +175,130 @@ func TestBackup(t *testing.T) {
 		}
 	}()
 }
+
+func TestBackupContext(t *testing.T) {
+	t.Parallel()
+
+	db, err := sqlite3.Open(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	err = db.Exec(`
+		CREATE TABLE t (x);
+		INSERT INTO t SELECT randomblob(1000) FROM generate_series(1, 100);
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var steps int
+	opts := sqlite3.BackupOptions{
+		Pages: 5,
+		Sleep: time.Millisecond,
+		Progress: func(remaining, pageCount int) {
+			steps++
+			if remaining < 0 || remaining > pageCount {
+				t.Errorf("got %d/%d", remaining, pageCount)
+			}
+		},
+	}
+
+	// Keep the backup alive.
+	backup, err := sqlite3.Open("file:/backup.db?vfs=memdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer backup.Close()
+
+	ctx := context.Background()
+	err = db.BackupContext(ctx, "main", "file:/backup.db?vfs=memdb", &opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if steps < 5 {
+		t.Errorf("got %d steps", steps)
+	}
+
+	restored, err := sqlite3.Open(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer restored.Close()
+
+	err = restored.RestoreContext(ctx, "main", "file:/backup.db?vfs=memdb", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stmt, _, err := restored.Prepare(`SELECT count(*) FROM t`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stmt.Close()
+	if !stmt.Step() || stmt.ColumnInt(0) != 100 {
+		t.Error("want 100 rows")
+	}
+	if err := stmt.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	opts.Progress = func(int, int) { cancel() }
+	err = db.BackupContext(ctx, "main", "file:/canceled.db?vfs=memdb", &opts)
+	if err != context.Canceled {
+		t.Errorf("got %v, want context.Canceled", err)
+	}
+}
+
+func TestBackupContext_busy(t *testing.T) {
+	t.Parallel()
+
+	db, err := sqlite3.Open("file:/busy.db?vfs=memdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	err = db.Exec(`
+		CREATE TABLE t (x);
+		INSERT INTO t SELECT randomblob(1000) FROM generate_series(1, 100);
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lock, err := sqlite3.Open("file:/busy.db?vfs=memdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lock.Close()
+
+	err = lock.Exec(`BEGIN EXCLUSIVE`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error)
+	go func() {
+		time.Sleep(300 * time.Millisecond)
+		done <- lock.Exec(`COMMIT`)
+	}()
+
+	var steps int
+	opts := sqlite3.BackupOptions{
+		Pages:    -1,
+		Progress: func(int, int) { steps++ },
+	}
+	err = db.BackupContext(context.Background(), "main", "file:/busy-backup.db?vfs=memdb", &opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if steps < 2 || steps > 10 {
+		t.Errorf("got %d steps", steps)
+	}
+}
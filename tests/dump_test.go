@@ -0,0 +1,155 @@
+package tests
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/ncruces/go-sqlite3"
+	_ "github.com/ncruces/go-sqlite3/embed"
+	_ "github.com/ncruces/go-sqlite3/internal/testcfg"
+)
+
+func TestConn_Dump(t *testing.T) {
+	t.Parallel()
+
+	db, err := sqlite3.Open(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	err = db.Exec(`
+		CREATE TABLE users (id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT);
+		CREATE TABLE kv (k, v, size AS (length(v)), PRIMARY KEY (k)) WITHOUT ROWID;
+		CREATE VIRTUAL TABLE boxes USING rtree(id, x0, x1);
+		CREATE INDEX users_name ON users (name);
+		CREATE VIEW names AS SELECT name FROM users;
+		CREATE TRIGGER users_insert AFTER INSERT ON users BEGIN
+			UPDATE kv SET v = new.name WHERE k = new.id;
+		END;
+		INSERT INTO users (name) VALUES ('alice'), ('o''brien'), (NULL);
+		INSERT INTO kv VALUES (1.0, x'00ff'), ('nul', 'a'||char(0)||'b'), (2, 1e100);
+		INSERT INTO boxes VALUES (1, 0, 10);
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var dump strings.Builder
+	err = db.Dump(&dump, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	restored, err := sqlite3.Open(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer restored.Close()
+
+	var calls int
+	err = restored.ExecScript(strings.NewReader(dump.String()), func(read int64) error {
+		calls++
+		if read <= 0 || read > int64(dump.Len()) {
+			t.Errorf("got %d", read)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls < 10 {
+		t.Errorf("got %d calls", calls)
+	}
+
+	var again strings.Builder
+	err = restored.Dump(&again, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.String() != dump.String() {
+		t.Errorf("got %q, want %q", again.String(), dump.String())
+	}
+
+	stmt, _, err := restored.Prepare(`SELECT id FROM boxes WHERE x0 < 5 AND x1 > 5`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stmt.Close()
+	if !stmt.Step() || stmt.ColumnInt(0) != 1 {
+		t.Error("want match")
+	}
+	if err := stmt.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	var schema strings.Builder
+	err = db.Dump(&schema, &sqlite3.DumpOptions{SchemaOnly: true, Tables: []string{"users"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := schema.String(); strings.Contains(got, "INSERT INTO") ||
+		!strings.Contains(got, "users_name") || strings.Contains(got, "CREATE TABLE kv") {
+		t.Errorf("got %q", got)
+	}
+
+	var data strings.Builder
+	err = db.Dump(&data, &sqlite3.DumpOptions{DataOnly: true, Tables: []string{"kv"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `INSERT INTO "kv"("k","v") VALUES(1.0,x'00FF');` + "\n" +
+		`INSERT INTO "kv"("k","v") VALUES(2,1e+100);` + "\n" +
+		`INSERT INTO "kv"("k","v") VALUES('nul',CAST(x'610062' AS TEXT));` + "\n"
+	if got := data.String(); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestConn_ExecScript(t *testing.T) {
+	t.Parallel()
+
+	db, err := sqlite3.Open(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	script := `
+		CREATE TABLE t (x); -- comment; with semicolon
+		CREATE TEMP TRIGGER tr AFTER INSERT ON t BEGIN
+			SELECT ';';
+			SELECT /* ; */ 1;
+		END;
+		INSERT INTO t VALUES ('multi
+		line;');
+		INSERT INTO t VALUES (2)`
+
+	err = db.ExecScript(strings.NewReader(script), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stmt, _, err := db.Prepare(`SELECT count(*) FROM t`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stmt.Close()
+	if !stmt.Step() || stmt.ColumnInt(0) != 2 {
+		t.Error("want 2 rows")
+	}
+	stmt.Reset()
+
+	stop := errors.New("stop")
+	err = db.ExecScript(strings.NewReader(`INSERT INTO t VALUES (3); INSERT INTO t VALUES (4);`),
+		func(int64) error { return stop })
+	if err != stop {
+		t.Errorf("got %v, want stop", err)
+	}
+
+	err = db.ExecScript(strings.NewReader(`SELECT * FROM missing;`), nil)
+	if err == nil {
+		t.Error("want error")
+	}
+}
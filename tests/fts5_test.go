@@ -0,0 +1,174 @@
+package tests
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/ncruces/go-sqlite3"
+	_ "github.com/ncruces/go-sqlite3/embed"
+	_ "github.com/ncruces/go-sqlite3/internal/testcfg"
+)
+
+func TestConn_CreateTokenizer(t *testing.T) {
+	t.Parallel()
+
+	db, err := sqlite3.Open(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	var args []string
+	err = db.CreateTokenizer("comma", func(arg ...string) (sqlite3.FTS5Tokenizer, error) {
+		args = arg
+		return commaTokenizer{}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = db.Exec(`
+		CREATE VIRTUAL TABLE fruits USING fts5(name, tokenize='comma lower');
+		INSERT INTO fruits VALUES ('Red Apple, Green Pear');
+		INSERT INTO fruits VALUES ('Blue Sky, red apple');
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(args, []string{"lower"}) {
+		t.Errorf("got %q", args)
+	}
+
+	stmt, _, err := db.Prepare(`SELECT rowid FROM fruits WHERE fruits MATCH ? ORDER BY rowid`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stmt.Close()
+
+	tests := []struct {
+		query string
+		want  []int64
+	}{
+		{`"red apple"`, []int64{1, 2}},
+		{`"green pear"`, []int64{1}},
+		{`"blue"`, nil},
+	}
+	for _, tt := range tests {
+		stmt.BindText(1, tt.query)
+		var got []int64
+		for stmt.Step() {
+			got = append(got, stmt.ColumnInt64(0))
+		}
+		if err := stmt.Reset(); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestConn_CreateFTS5Function(t *testing.T) {
+	t.Parallel()
+
+	db, err := sqlite3.Open(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	// matches(fts, sep) returns the rowid, the number of phrase instances,
+	// and the matched tokens, separated by sep.
+	err = db.CreateFTS5Function("matches", func(ctx sqlite3.Context, fts sqlite3.FTS5Context, arg ...sqlite3.Value) {
+		n, err := fts.InstCount()
+		if err != nil {
+			ctx.ResultError(err)
+			return
+		}
+		text, err := fts.ColumnText(0)
+		if err != nil {
+			ctx.ResultError(err)
+			return
+		}
+		words := bytes.Fields([]byte(text))
+
+		res := fmt.Sprintf("%d:%d", fts.RowID(), n)
+		for i := 0; i < n; i++ {
+			_, col, off, err := fts.Inst(i)
+			if err != nil {
+				ctx.ResultError(err)
+				return
+			}
+			if col != 0 || off >= len(words) {
+				ctx.ResultError(fmt.Errorf("unexpected instance: %d, %d", col, off))
+				return
+			}
+			res += arg[0].Text() + string(words[off])
+		}
+		ctx.ResultText(res)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = db.Exec(`
+		CREATE VIRTUAL TABLE docs USING fts5(body);
+		INSERT INTO docs VALUES ('one two one');
+		INSERT INTO docs VALUES ('two three');
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stmt, _, err := db.Prepare(`SELECT matches(docs, ',') FROM docs WHERE docs MATCH ? ORDER BY rowid`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stmt.Close()
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{`one`, []string{"1:2,one,one"}},
+		{`two`, []string{"1:1,two", "2:1,two"}},
+		{`two OR three`, []string{"1:1,two", "2:2,two,three"}},
+		{`four`, nil},
+	}
+	for _, tt := range tests {
+		stmt.BindText(1, tt.query)
+		var got []string
+		for stmt.Step() {
+			got = append(got, stmt.ColumnText(0))
+		}
+		if err := stmt.Reset(); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
+
+// commaTokenizer splits text on commas,
+// and lowercases tokens.
+type commaTokenizer struct{}
+
+func (commaTokenizer) Tokenize(text []byte, _ sqlite3.FTS5TokenizeFlag, token func([]byte, sqlite3.FTS5TokenFlag, int, int) error) error {
+	start := 0
+	for i := 0; i <= len(text); i++ {
+		if i < len(text) && text[i] != ',' {
+			continue
+		}
+		if tok := bytes.TrimSpace(text[start:i]); len(tok) > 0 {
+			err := token(bytes.ToLower(tok), 0, start, i)
+			if err != nil {
+				return err
+			}
+		}
+		start = i + 1
+	}
+	return nil
+}
@@ -317,3 +317,70 @@ func TestPointer(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+func TestSubtype(t *testing.T) {
+	t.Parallel()
+
+	db, err := sqlite3.Open(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	err = db.CreateFunction("subtype", 1, sqlite3.DETERMINISTIC|sqlite3.SUBTYPE, func(ctx sqlite3.Context, arg ...sqlite3.Value) {
+		ctx.ResultInt64(int64(arg[0].Subtype()))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = db.CreateFunction("raw_json", 1, sqlite3.DETERMINISTIC|sqlite3.RESULT_SUBTYPE, func(ctx sqlite3.Context, arg ...sqlite3.Value) {
+		ctx.ResultRawJSON(arg[0].RawText())
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = db.CreateFunction("tagged", 2, sqlite3.DETERMINISTIC|sqlite3.RESULT_SUBTYPE, func(ctx sqlite3.Context, arg ...sqlite3.Value) {
+		ctx.ResultValue(arg[0])
+		ctx.ResultSubtype(uint(arg[1].Int()))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stmt, _, err := db.Prepare(`
+		SELECT
+			subtype(json('{}')),
+			subtype('{}'),
+			subtype(tagged('x', 42)),
+			json_extract(raw_json('{"a":[1,2]}'), '$.a[1]'),
+			json_array(raw_json('{"a":[1,2]}')),
+			json_array('{"a":[1,2]}')`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stmt.Close()
+
+	if !stmt.Step() {
+		t.Fatal(stmt.Err())
+	}
+	if got := stmt.ColumnInt64(0); got != 'J' {
+		t.Errorf("got %d, want %d", got, 'J')
+	}
+	if got := stmt.ColumnInt64(1); got != 0 {
+		t.Errorf("got %d, want 0", got)
+	}
+	if got := stmt.ColumnInt64(2); got != 42 {
+		t.Errorf("got %d, want 42", got)
+	}
+	if got := stmt.ColumnInt64(3); got != 2 {
+		t.Errorf("got %d, want 2", got)
+	}
+	if got := stmt.ColumnText(4); got != `[{"a":[1,2]}]` {
+		t.Errorf("got %s", got)
+	}
+	if got := stmt.ColumnText(5); got != `["{\"a\":[1,2]}"]` {
+		t.Errorf("got %s", got)
+	}
+}
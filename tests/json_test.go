@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"math"
+	"reflect"
 	"testing"
 	"time"
 
@@ -79,3 +80,180 @@ func TestJSON(t *testing.T) {
 		want = want[1:]
 	}
 }
+
+func TestJSONB(t *testing.T) {
+	t.Parallel()
+
+	db, err := driver.Open("file:/jsonb.db?vfs=memdb&_jsonfmt=jsonb", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`CREATE TABLE test (col)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]any{"a": []any{1.0, "b", true}}
+	_, err = db.Exec(`INSERT INTO test VALUES (?)`, sqlite3.JSON(want))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var typ, text string
+	err = db.QueryRow(`SELECT typeof(col), json(col) FROM test`).Scan(&typ, &text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if typ != "blob" {
+		t.Errorf("got %s, want blob", typ)
+	}
+	if text != `{"a":[1,"b",true]}` {
+		t.Errorf("got %s", text)
+	}
+
+	var got map[string]any
+	err = db.QueryRow(`SELECT col FROM test`).Scan(sqlite3.JSONB(&got))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	got = nil
+	err = db.QueryRow(`SELECT json(col) FROM test`).Scan(sqlite3.JSONB(&got))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	conn, err := sqlite3.Open(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	stmt, _, err := conn.Prepare(`SELECT ?, jsonb('[1,2]'), json(?)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stmt.Close()
+
+	err = stmt.BindJSONB(1, want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = stmt.BindJSONB(2, want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stmt.Step() {
+		got = nil
+		err = stmt.ColumnJSONB(0, &got)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("got %v, want %v", got, want)
+		}
+
+		var arr []int
+		err = stmt.ColumnJSONB(1, &arr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(arr, []int{1, 2}) {
+			t.Errorf("got %v", arr)
+		}
+
+		if got := stmt.ColumnText(2); got != `{"a":[1,"b",true]}` {
+			t.Errorf("got %s", got)
+		}
+	}
+	err = stmt.Err()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = driver.Open("file:/jsonb.db?vfs=memdb&_jsonfmt=xml", nil)
+	if err == nil {
+		t.Error("want error")
+	}
+}
+
+func TestJSON_blob(t *testing.T) {
+	t.Parallel()
+
+	// JSON text stored as a BLOB, that is also valid JSONB.
+	const text = "3123"
+
+	db, err := driver.Open("file:/jsonblob.db?vfs=memdb", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`CREATE TABLE test (col)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Exec(`INSERT INTO test VALUES (?)`, []byte(text))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got int
+	err = db.QueryRow(`SELECT col FROM test`).Scan(sqlite3.JSON(&got))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != 3123 {
+		t.Errorf("got %d, want 3123", got)
+	}
+
+	conn, err := sqlite3.Open("file:/jsonblob.db?vfs=memdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	err = conn.CreateFunction("json_value", 1, sqlite3.DETERMINISTIC, func(ctx sqlite3.Context, arg ...sqlite3.Value) {
+		var got int
+		if err := arg[0].JSON(&got); err != nil {
+			ctx.ResultError(err)
+		} else {
+			ctx.ResultInt(got)
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stmt, _, err := conn.Prepare(`SELECT col, json_value(col) FROM test`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stmt.Close()
+
+	if stmt.Step() {
+		got = 0
+		err = stmt.ColumnJSON(0, &got)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != 3123 {
+			t.Errorf("got %d, want 3123", got)
+		}
+		if got := stmt.ColumnInt(1); got != 3123 {
+			t.Errorf("got %d, want 3123", got)
+		}
+	}
+	err = stmt.Err()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,128 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ncruces/go-sqlite3"
+	_ "github.com/ncruces/go-sqlite3/embed"
+	_ "github.com/ncruces/go-sqlite3/internal/testcfg"
+	"github.com/ncruces/go-sqlite3/vfs"
+)
+
+func TestPool(t *testing.T) {
+	if !vfs.SupportsSharedMemory {
+		t.Skip("skipping without shared memory")
+	}
+	t.Parallel()
+
+	ctx := context.Background()
+	name := "file:" + filepath.ToSlash(filepath.Join(t.TempDir(), "test.db")) +
+		"?_pragma=busy_timeout(10000)&_pragma=journal_mode(wal)"
+
+	pool, err := sqlite3.OpenPool(name, sqlite3.PoolConfig{
+		Size:   2,
+		Writer: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := pool.TakeWriter(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = w.Exec(`CREATE TABLE test (col)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool.Put(w)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			w, err := pool.TakeWriter(ctx)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			defer pool.Put(w)
+
+			err = w.Exec(`INSERT INTO test VALUES (1)`)
+			if err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	r1, err := pool.Take(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r2, err := pool.Take(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = r1.Exec(`INSERT INTO test VALUES (1)`)
+	if !errors.Is(err, sqlite3.READONLY) {
+		t.Errorf("got %v, want sqlite3.READONLY", err)
+	}
+
+	stmt, _, err := r2.Prepare(`SELECT count(*) FROM test`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stmt.Step() && stmt.ColumnInt(0) != 10 {
+		t.Errorf("got %d rows, want 10", stmt.ColumnInt(0))
+	}
+	err = stmt.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The pool is exhausted.
+	tctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	_, err = pool.Take(tctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v, want context.DeadlineExceeded", err)
+	}
+
+	// Transactions are rolled back.
+	r1.Begin()
+	pool.Put(r1)
+	pool.Put(r2)
+
+	r1, err = pool.Take(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !r1.GetAutocommit() {
+		t.Error("want autocommit")
+	}
+	pool.Put(r1)
+
+	err = pool.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = pool.Take(ctx)
+	if err == nil {
+		t.Error("want error")
+	}
+
+	err = pool.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
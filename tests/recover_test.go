@@ -0,0 +1,151 @@
+package tests
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ncruces/go-sqlite3"
+	_ "github.com/ncruces/go-sqlite3/embed"
+	_ "github.com/ncruces/go-sqlite3/internal/testcfg"
+	"github.com/ncruces/go-sqlite3/util/fileformat"
+)
+
+func TestConn_Recover(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	name := filepath.Join(dir, "corrupt.db")
+	createCorrupt(t, name)
+
+	db, err := sqlite3.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	err = db.Exec(`SELECT count(*) FROM t`)
+	if !errors.Is(err, sqlite3.CORRUPT) {
+		t.Fatalf("got %v, want CORRUPT", err)
+	}
+
+	dst := filepath.Join(dir, "recovered.db")
+	err = db.Recover("file:"+filepath.ToSlash(dst), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recovered, err := sqlite3.Open(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer recovered.Close()
+	checkRecovered(t, recovered)
+
+	var buf strings.Builder
+	err = db.RecoverSQL(&buf, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "CREATE TABLE") {
+		t.Errorf("got %q", buf.String())
+	}
+
+	replayed, err := sqlite3.Open(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer replayed.Close()
+
+	err = replayed.Exec(buf.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkRecovered(t, replayed)
+}
+
+// createCorrupt creates a database with 1000 rows in table t,
+// and zeroes one of its leaf pages.
+func createCorrupt(t *testing.T, name string) {
+	t.Helper()
+
+	db, err := sqlite3.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.Exec(`
+		CREATE TABLE t (id INTEGER PRIMARY KEY, v TEXT);
+		INSERT INTO t SELECT value, printf('%.100c', char(65 + value % 26))
+		FROM generate_series(1, 1000);
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.OpenFile(name, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	hdr, err := fileformat.ReadHeader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var leaves []uint32
+	err = fileformat.WalkBTree(f, hdr, 2, func(p *fileformat.BTreePage) error {
+		if p.Type.IsLeaf() {
+			leaves = append(leaves, p.Number)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(leaves) < 10 {
+		t.Fatalf("got %d leaf pages", len(leaves))
+	}
+
+	zero := make([]byte, hdr.PageSize)
+	_, err = f.WriteAt(zero, int64(leaves[len(leaves)/2]-1)*int64(hdr.PageSize))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// checkRecovered checks that most rows of table t were recovered intact.
+func checkRecovered(t *testing.T, db *sqlite3.Conn) {
+	t.Helper()
+
+	stmt, _, err := db.Prepare(`SELECT id, v FROM t`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stmt.Close()
+
+	var rows int
+	for stmt.Step() {
+		rows++
+		id := stmt.ColumnInt(0)
+		want := strings.Repeat(string(rune('A'+id%26)), 100)
+		if got := stmt.ColumnText(1); got != want {
+			t.Errorf("row %d: got %q", id, got)
+		}
+	}
+	if err := stmt.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if rows < 900 || rows >= 1000 {
+		t.Errorf("got %d rows", rows)
+	}
+}
@@ -0,0 +1,94 @@
+package tests
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/ncruces/go-sqlite3"
+	_ "github.com/ncruces/go-sqlite3/embed"
+	_ "github.com/ncruces/go-sqlite3/internal/testcfg"
+)
+
+func TestConn_CreateGeometry(t *testing.T) {
+	t.Parallel()
+
+	db, err := sqlite3.Open(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	// within(minX, maxX, minY, maxY) matches entries fully within the box.
+	err = db.CreateGeometry("within", func(q *sqlite3.RTreeQuery) (sqlite3.RTreeWithin, float64, error) {
+		if len(q.Params) != 4 {
+			return sqlite3.NOT_WITHIN, 0, errors.New("within: wrong number of arguments")
+		}
+		if len(q.Coords) != 4 {
+			return sqlite3.NOT_WITHIN, 0, errors.New("within: wrong number of dimensions")
+		}
+		b, c := q.Params, q.Coords
+		switch {
+		case c[0] >= b[0] && c[1] <= b[1] && c[2] >= b[2] && c[3] <= b[3]:
+			return sqlite3.FULLY_WITHIN, float64(q.Level), nil
+		case c[1] < b[0] || c[0] > b[1] || c[3] < b[2] || c[2] > b[3]:
+			return sqlite3.NOT_WITHIN, 0, nil
+		case q.Level == 0:
+			// Entries that only overlap the box don't match.
+			return sqlite3.NOT_WITHIN, 0, nil
+		default:
+			return sqlite3.PARTLY_WITHIN, float64(q.Level), nil
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = db.Exec(`
+		CREATE VIRTUAL TABLE boxes USING rtree(id, minX, maxX, minY, maxY);
+		INSERT INTO boxes VALUES (1, 0, 1, 0, 1);
+		INSERT INTO boxes VALUES (2, 4, 6, 4, 6);
+		INSERT INTO boxes VALUES (3, 10, 11, 10, 11);
+		INSERT INTO boxes VALUES (4, 2, 3, 1, 5);
+		INSERT INTO boxes SELECT value + 10, value, value + 1, 20, 21 FROM generate_series(0, 100);
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stmt, _, err := db.Prepare(`SELECT id FROM boxes WHERE id MATCH within(?, ?, ?, ?) ORDER BY id`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stmt.Close()
+
+	tests := []struct {
+		box  [4]float64
+		want []int64
+	}{
+		{[4]float64{0, 5, 0, 5}, []int64{1, 4}},
+		{[4]float64{0, 12, 0, 12}, []int64{1, 2, 3, 4}},
+		{[4]float64{50, 52.5, 19, 22}, []int64{60, 61}},
+		{[4]float64{-5, -1, -5, -1}, nil},
+	}
+	for _, tt := range tests {
+		for i, v := range tt.box {
+			stmt.BindFloat(i+1, v)
+		}
+		var got []int64
+		for stmt.Step() {
+			got = append(got, stmt.ColumnInt64(0))
+		}
+		if err := stmt.Reset(); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%v: got %v, want %v", tt.box, got, tt.want)
+		}
+	}
+
+	err = db.Exec(`SELECT id FROM boxes WHERE id MATCH within(1, 2)`)
+	if err == nil {
+		t.Error("want error")
+	}
+}
@@ -0,0 +1,219 @@
+package tests
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ncruces/go-sqlite3"
+	_ "github.com/ncruces/go-sqlite3/embed"
+	_ "github.com/ncruces/go-sqlite3/internal/testcfg"
+)
+
+func TestConn_Schema(t *testing.T) {
+	t.Parallel()
+
+	db, err := sqlite3.Open(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	err = db.Exec(`
+		CREATE TABLE users (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT COLLATE NOCASE NOT NULL DEFAULT '',
+			email VARCHAR(255) UNIQUE,
+			score REAL
+		);
+		CREATE TABLE posts (
+			user_id INTEGER REFERENCES users ON DELETE CASCADE,
+			slug TEXT,
+			body,
+			size AS (length(body)) STORED,
+			PRIMARY KEY (user_id, slug)
+		) WITHOUT ROWID;
+		CREATE TABLE tags (name ANY) STRICT;
+		CREATE INDEX posts_lower ON posts (lower(slug) DESC, user_id) WHERE body IS NOT NULL;
+		CREATE VIEW names AS SELECT name FROM users;
+		CREATE TRIGGER users_delete AFTER DELETE ON users BEGIN SELECT 1; END;
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	schema, err := db.Schema("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(schema.Tables) != 3 {
+		t.Fatalf("got %d tables, want 3", len(schema.Tables))
+	}
+
+	users := schema.Tables[0]
+	if users.Name != "users" || users.WithoutRowID || users.Strict {
+		t.Errorf("got %+v", users)
+	}
+	want := []sqlite3.Column{
+		{Name: "id", Type: "INTEGER", Affinity: sqlite3.AFF_INTEGER, Collation: "BINARY", PrimaryKey: 1, AutoIncrement: true},
+		{Name: "name", Type: "TEXT", Affinity: sqlite3.AFF_TEXT, Collation: "NOCASE", NotNull: true, Default: "''"},
+		{Name: "email", Type: "VARCHAR(255)", Affinity: sqlite3.AFF_TEXT, Collation: "BINARY"},
+		{Name: "score", Type: "REAL", Affinity: sqlite3.AFF_REAL, Collation: "BINARY"},
+	}
+	if !reflect.DeepEqual(users.Columns, want) {
+		t.Errorf("got %+v, want %+v", users.Columns, want)
+	}
+	if len(users.Indexes) != 1 || users.Indexes[0].Origin != "u" || !users.Indexes[0].Unique ||
+		users.Indexes[0].Columns[0].Name != "email" {
+		t.Errorf("got %+v", users.Indexes)
+	}
+
+	posts := schema.Tables[1]
+	if !posts.WithoutRowID {
+		t.Error("want WITHOUT ROWID")
+	}
+	if got := posts.Columns[2].Affinity; got != sqlite3.AFF_BLOB {
+		t.Errorf("got %s, want BLOB", got)
+	}
+	if got := posts.Columns[3].Generated; got != "STORED" {
+		t.Errorf("got %q, want STORED", got)
+	}
+	if got := posts.Columns[1].PrimaryKey; got != 2 {
+		t.Errorf("got %d, want 2", got)
+	}
+	wantFK := []sqlite3.ForeignKey{{
+		Columns:  []string{"user_id"},
+		Table:    "users",
+		OnUpdate: "NO ACTION",
+		OnDelete: "CASCADE",
+		Match:    "NONE",
+	}}
+	if !reflect.DeepEqual(posts.ForeignKeys, wantFK) {
+		t.Errorf("got %+v, want %+v", posts.ForeignKeys, wantFK)
+	}
+
+	var idx *sqlite3.Index
+	for i := range posts.Indexes {
+		if posts.Indexes[i].Name == "posts_lower" {
+			idx = &posts.Indexes[i]
+		}
+	}
+	if idx == nil {
+		t.Fatalf("got %+v", posts.Indexes)
+	}
+	if idx.Where != "body IS NOT NULL" {
+		t.Errorf("got %q", idx.Where)
+	}
+	wantIdx := []sqlite3.IndexColumn{
+		{Expr: "lower(slug) DESC", Desc: true, Collation: "BINARY"},
+		{Name: "user_id", Collation: "BINARY"},
+	}
+	if !reflect.DeepEqual(idx.Columns, wantIdx) {
+		t.Errorf("got %+v, want %+v", idx.Columns, wantIdx)
+	}
+
+	tags := schema.Tables[2]
+	if !tags.Strict || tags.Columns[0].Affinity != sqlite3.AFF_BLOB {
+		t.Errorf("got %+v", tags)
+	}
+
+	if len(schema.Views) != 1 || schema.Views[0].Name != "names" ||
+		len(schema.Views[0].Columns) != 1 {
+		t.Errorf("got %+v", schema.Views)
+	}
+	if len(schema.Triggers) != 1 || schema.Triggers[0].Table != "users" {
+		t.Errorf("got %+v", schema.Triggers)
+	}
+
+	_, err = db.Schema("missing")
+	if err == nil {
+		t.Error("want error")
+	}
+}
+
+func TestConn_Schema_autoIncrement(t *testing.T) {
+	t.Parallel()
+
+	db, err := sqlite3.Open(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	err = db.Exec(`
+		CREATE TABLE seq (id INTEGER, PRIMARY KEY (id AUTOINCREMENT));
+		-- AUTOINCREMENT
+		CREATE TABLE lookalike (
+			id INTEGER PRIMARY KEY, -- not AUTOINCREMENT
+			"autoincrement" TEXT DEFAULT 'AUTOINCREMENT'
+		);
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	schema, err := db.Schema("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(schema.Tables) != 2 {
+		t.Fatalf("got %d tables, want 2", len(schema.Tables))
+	}
+	if col := schema.Tables[0].Columns[0]; !col.AutoIncrement {
+		t.Errorf("got %+v, want AUTOINCREMENT", col)
+	}
+	for _, col := range schema.Tables[1].Columns {
+		if col.AutoIncrement {
+			t.Errorf("got %+v, want no AUTOINCREMENT", col)
+		}
+	}
+}
+
+func TestConn_TableColumnMetadata(t *testing.T) {
+	t.Parallel()
+
+	db, err := sqlite3.Open(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	err = db.Exec(`
+		CREATE TABLE users (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT COLLATE NOCASE NOT NULL
+		)
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	declType, collSeq, notNull, primaryKey, autoInc, err := db.TableColumnMetadata("main", "users", "id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if declType != "INTEGER" || collSeq != "BINARY" || notNull || !primaryKey || !autoInc {
+		t.Errorf("got %q, %q, %v, %v, %v", declType, collSeq, notNull, primaryKey, autoInc)
+	}
+
+	declType, collSeq, notNull, primaryKey, autoInc, err = db.TableColumnMetadata("", "users", "name")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if declType != "TEXT" || collSeq != "NOCASE" || !notNull || primaryKey || autoInc {
+		t.Errorf("got %q, %q, %v, %v, %v", declType, collSeq, notNull, primaryKey, autoInc)
+	}
+
+	_, _, _, _, _, err = db.TableColumnMetadata("main", "users", "")
+	if err != nil {
+		t.Error(err)
+	}
+	_, _, _, _, _, err = db.TableColumnMetadata("main", "missing", "")
+	if err == nil {
+		t.Error("want error")
+	}
+	_, _, _, _, _, err = db.TableColumnMetadata("main", "users", "missing")
+	if err == nil {
+		t.Error("want error")
+	}
+}
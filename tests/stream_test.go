@@ -0,0 +1,156 @@
+package tests
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/ncruces/go-sqlite3"
+	_ "github.com/ncruces/go-sqlite3/embed"
+	_ "github.com/ncruces/go-sqlite3/internal/testcfg"
+)
+
+func TestConn_RestoreFrom(t *testing.T) {
+	t.Parallel()
+
+	data, err := os.ReadFile("testdata/utf16be.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write(data)
+	zw.Close()
+
+	zr, err := gzip.NewReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := sqlite3.Open(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	err = db.RestoreFrom(context.Background(), "", zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stmt, _, err := db.Prepare(`PRAGMA encoding`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stmt.Close()
+	if !stmt.Step() || stmt.ColumnText(0) != "UTF-16be" {
+		t.Error("want UTF-16be")
+	}
+	if err := stmt.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// WAL mode databases are restored.
+	wal, err := os.ReadFile("testdata/wal.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.RestoreFrom(context.Background(), "", bytes.NewReader(wal))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = db.RestoreFrom(context.Background(), "", bytes.NewReader(data[:50]))
+	if err == nil {
+		t.Error("want error")
+	}
+}
+
+func TestConn_BackupTo(t *testing.T) {
+	t.Parallel()
+
+	src, err := sqlite3.Open(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer src.Close()
+
+	// A small cache makes VACUUM INTO spill pages out of order.
+	err = src.Exec(`
+		PRAGMA cache_size=10;
+		CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT);
+		CREATE INDEX users_name ON users (name);
+		INSERT INTO users SELECT value, hex(randomblob(50)) FROM generate_series(1, 1000);
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	err = src.BackupTo(context.Background(), "", zw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = zw.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	zr, err := gzip.NewReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dst, err := sqlite3.Open(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dst.Close()
+
+	err = dst.RestoreFrom(context.Background(), "", zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := dumpUsers(t, src)
+	got := dumpUsers(t, dst)
+	if len(got) != 1000 || !reflect.DeepEqual(got, want) {
+		t.Errorf("got %d rows, want %d rows", len(got), len(want))
+	}
+
+	stmt, _, err := dst.Prepare(`PRAGMA integrity_check`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stmt.Close()
+	if !stmt.Step() || stmt.ColumnText(0) != "ok" {
+		t.Errorf("got %q", stmt.ColumnText(0))
+	}
+	if err := stmt.Err(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func dumpUsers(t *testing.T, db *sqlite3.Conn) map[int64]string {
+	t.Helper()
+
+	stmt, _, err := db.Prepare(`SELECT id, name FROM users`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stmt.Close()
+
+	res := map[int64]string{}
+	for stmt.Step() {
+		res[stmt.ColumnInt64(0)] = stmt.ColumnText(1)
+	}
+	if err := stmt.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return res
+}
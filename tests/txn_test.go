@@ -3,11 +3,13 @@ package tests
 import (
 	"context"
 	"errors"
+	"path/filepath"
 	"testing"
 
 	"github.com/ncruces/go-sqlite3"
 	_ "github.com/ncruces/go-sqlite3/embed"
 	_ "github.com/ncruces/go-sqlite3/internal/testcfg"
+	"github.com/ncruces/go-sqlite3/vfs"
 	_ "github.com/ncruces/go-sqlite3/vfs/memdb"
 )
 
@@ -604,3 +606,131 @@ func TestConn_Savepoint_rollback(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+func TestConn_WithTx(t *testing.T) {
+	if !vfs.SupportsFileLocking {
+		t.Skip("skipping without locks")
+	}
+	t.Parallel()
+
+	file := "file:" + filepath.ToSlash(filepath.Join(t.TempDir(), "test.db")) +
+		"?_pragma=busy_timeout(0)"
+
+	db1, err := sqlite3.Open(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db1.Close()
+
+	db2, err := sqlite3.Open(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db2.Close()
+
+	err = db1.Exec(`CREATE TABLE test (col)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tx, err := db1.BeginImmediate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var retries int
+	err = db2.WithTx(context.Background(), sqlite3.TxnImmediate, func(c *sqlite3.Conn) error {
+		return c.Exec(`INSERT INTO test VALUES (1)`)
+	}, sqlite3.RetryPolicy{
+		OnRetry: func(attempt int, err error) {
+			if !errors.Is(err, sqlite3.BUSY) {
+				t.Errorf("got %v, want sqlite3.BUSY", err)
+			}
+			retries = attempt
+			tx.Commit()
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if retries != 1 {
+		t.Errorf("got %d retries, want 1", retries)
+	}
+
+	errFailed := errors.New("failed")
+	err = db2.WithTx(context.Background(), sqlite3.TxnDeferred, func(c *sqlite3.Conn) error {
+		err := c.Exec(`INSERT INTO test VALUES (2)`)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return errFailed
+	})
+	if err != errFailed {
+		t.Errorf("got %v, want errFailed", err)
+	}
+
+	stmt, _, err := db1.Prepare(`SELECT count(*) FROM test`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stmt.Close()
+	if stmt.Step() && stmt.ColumnInt(0) != 1 {
+		t.Errorf("got %d rows, want 1", stmt.ColumnInt(0))
+	}
+}
+
+func TestConn_StmtHook(t *testing.T) {
+	t.Parallel()
+
+	db, err := sqlite3.Open(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	var errs []error
+	db.StmtHook(func(err error) { errs = append(errs, err) })
+
+	err = db.Exec(`CREATE TABLE test (col UNIQUE)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stmt, _, err := db.Prepare(`INSERT INTO test VALUES (1) RETURNING col`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stmt.Close()
+
+	if !stmt.Step() {
+		t.Fatal(stmt.Err())
+	}
+	if len(errs) != 1 {
+		t.Fatalf("got %d calls, want 1", len(errs))
+	}
+	err = stmt.Reset()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stmt.Step() {
+		t.Fatal("want error")
+	}
+	err = stmt.Reset()
+	if !errors.Is(err, sqlite3.CONSTRAINT) {
+		t.Errorf("got %v, want CONSTRAINT", err)
+	}
+
+	// CREATE, the first INSERT (reset before it finished), the second INSERT.
+	if len(errs) != 3 || errs[0] != nil || errs[1] != nil || !errors.Is(errs[2], sqlite3.CONSTRAINT) {
+		t.Errorf("got %v", errs)
+	}
+
+	db.StmtHook(nil)
+	err = db.Exec(`DELETE FROM test`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(errs) != 3 {
+		t.Errorf("got %d calls, want 3", len(errs))
+	}
+}
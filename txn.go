@@ -8,6 +8,7 @@ import (
 	"runtime"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/ncruces/go-sqlite3/internal/util"
 	"github.com/tetratelabs/wazero/api"
@@ -220,6 +221,108 @@ func (s Savepoint) Rollback() error {
 	return s.c.txnExecInterrupted(fmt.Sprintf("ROLLBACK TO %q;", s.name))
 }
 
+// TxnMode is the locking mode of a transaction.
+//
+// https://sqlite.org/lang_transaction.html#deferred_immediate_and_exclusive_transactions
+type TxnMode string
+
+const (
+	TxnDeferred  TxnMode = "DEFERRED"
+	TxnImmediate TxnMode = "IMMEDIATE"
+	TxnExclusive TxnMode = "EXCLUSIVE"
+)
+
+// WithTx runs fn in a transaction started with mode,
+// committing if fn returns nil, rolling back otherwise.
+//
+// If the transaction fails with a retryable error
+// ([BUSY], [BUSY_SNAPSHOT], [LOCKED], etc.),
+// it is rolled back, and fn is run again after a delay,
+// as configured by retry.
+//
+// The connection is interrupted when ctx is done: see [Conn.SetInterrupt].
+func (c *Conn) WithTx(ctx context.Context, mode TxnMode, fn func(*Conn) error, retry ...RetryPolicy) error {
+	var policy RetryPolicy
+	if len(retry) > 0 {
+		policy = retry[0]
+	}
+
+	old := c.SetInterrupt(ctx)
+	defer c.SetInterrupt(old)
+
+	return policy.Retry(ctx, func() (err error) {
+		err = c.Exec(`BEGIN ` + string(mode))
+		if err != nil {
+			return err
+		}
+		tx := Txn{c}
+		defer tx.End(&err)
+		return fn(c)
+	})
+}
+
+// RetryPolicy configures how operations are retried,
+// see [Conn.WithTx].
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of times an operation is tried.
+	// If zero, a default of 10 is used.
+	MaxAttempts int
+	// OnRetry is called before each retry,
+	// with the number of failed attempts so far, and the last error.
+	OnRetry func(attempt int, err error)
+}
+
+// Retry calls fn until it succeeds,
+// returns an error that is not retryable,
+// the maximum number of attempts is reached,
+// or ctx is done.
+// Retries are delayed with exponential backoff and jitter.
+//
+// An error is retryable if it is [Error.Temporary], [Error.Timeout],
+// or a [LOCKED] error.
+func (p RetryPolicy) Retry(ctx context.Context, fn func() error) error {
+	const minDelay = time.Millisecond
+	const maxDelay = 100 * time.Millisecond
+
+	attempts := p.MaxAttempts
+	if attempts <= 0 {
+		attempts = 10
+	}
+
+	delay := minDelay
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= attempts || !retryable(err) {
+			return err
+		}
+		if p.OnRetry != nil {
+			p.OnRetry(attempt, err)
+		}
+
+		// Full jitter: sleep a random duration up to delay.
+		timer := time.NewTimer(time.Duration(rand.Int63n(int64(delay))) + 1)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+		delay = min(2*delay, maxDelay)
+	}
+}
+
+func retryable(err error) bool {
+	var temporary interface{ Temporary() bool }
+	if errors.As(err, &temporary) && temporary.Temporary() {
+		return true
+	}
+	var timeout interface{ Timeout() bool }
+	if errors.As(err, &timeout) && timeout.Timeout() {
+		return true
+	}
+	return errors.Is(err, LOCKED)
+}
+
 func (c *Conn) txnExecInterrupted(sql string) error {
 	err := c.Exec(sql)
 	if errors.Is(err, INTERRUPT) {
@@ -283,6 +386,20 @@ func (c *Conn) UpdateHook(cb func(action AuthorizerActionCode, schema, table str
 	c.update = cb
 }
 
+// StmtHook registers a callback function to be invoked
+// whenever a statement finishes running,
+// with the error that stopped it, if any.
+//
+// The callback is invoked when [Stmt.Step] returns false,
+// when [Stmt.Reset] resets a statement that hasn't finished,
+// and when [Conn.Exec] returns (once for all its statements).
+// By then, a transaction the statement committed is durable,
+// so the callback can publish changes collected by other hooks.
+// The callback must not run statements.
+func (c *Conn) StmtHook(cb func(err error)) {
+	c.stmt = cb
+}
+
 func commitCallback(ctx context.Context, mod api.Module, pDB uint32) (rollback uint32) {
 	if c, ok := ctx.Value(connKey{}).(*Conn); ok && c.handle == pDB && c.commit != nil {
 		if !c.commit() {
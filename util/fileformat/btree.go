@@ -0,0 +1,311 @@
+package fileformat
+
+import (
+	"encoding/binary"
+	"io"
+	"math"
+)
+
+// PageType is the type of a B-tree page.
+//
+// https://sqlite.org/fileformat.html#b_tree_pages
+type PageType uint8
+
+const (
+	INTERIOR_INDEX PageType = 2
+	INTERIOR_TABLE PageType = 5
+	LEAF_INDEX     PageType = 10
+	LEAF_TABLE     PageType = 13
+)
+
+// IsLeaf reports whether t is a leaf page type.
+func (t PageType) IsLeaf() bool {
+	return t == LEAF_INDEX || t == LEAF_TABLE
+}
+
+// IsTable reports whether t is a table page type.
+func (t PageType) IsTable() bool {
+	return t == INTERIOR_TABLE || t == LEAF_TABLE
+}
+
+// BTreePage is a parsed B-tree page.
+type BTreePage struct {
+	Number uint32
+	Type   PageType
+	// Depth is the depth of the page, starting at zero for the root page.
+	Depth int
+	Cells []Cell
+	// RightChild is the right-most child of interior pages.
+	RightChild uint32
+}
+
+// Cell is a B-tree cell.
+//
+// https://sqlite.org/fileformat.html#b_tree_cell_format
+type Cell struct {
+	// LeftChild is the left child of interior pages.
+	LeftChild uint32
+	// RowID is the key of table pages.
+	RowID int64
+	// PayloadSize is the total size of the payload.
+	PayloadSize int64
+	// Payload is the part of the payload stored in the page.
+	Payload []byte
+	// Overflow is the first overflow page, or zero.
+	Overflow uint32
+}
+
+// ParseBTreePage parses page number pgno.
+func ParseBTreePage(page []byte, pgno uint32, usableSize int) (*BTreePage, error) {
+	hdr := 0
+	if pgno == 1 {
+		hdr = HeaderSize
+	}
+	if len(page) < hdr+12 || usableSize > len(page) {
+		return nil, ErrCorrupt
+	}
+
+	p := BTreePage{Number: pgno, Type: PageType(page[hdr])}
+	ptrs := hdr + 8
+	switch p.Type {
+	case INTERIOR_INDEX, INTERIOR_TABLE:
+		p.RightChild = binary.BigEndian.Uint32(page[hdr+8:])
+		ptrs += 4
+	case LEAF_INDEX, LEAF_TABLE:
+	default:
+		return nil, ErrCorrupt
+	}
+
+	count := int(binary.BigEndian.Uint16(page[hdr+3:]))
+	if ptrs+2*count > usableSize {
+		return nil, ErrCorrupt
+	}
+	p.Cells = make([]Cell, count)
+	for i := range p.Cells {
+		off := int(binary.BigEndian.Uint16(page[ptrs+2*i:]))
+		if off < ptrs+2*count || off >= usableSize {
+			return nil, ErrCorrupt
+		}
+		if err := parseCell(&p.Cells[i], p.Type, page[off:usableSize], usableSize); err != nil {
+			return nil, err
+		}
+	}
+	return &p, nil
+}
+
+func parseCell(c *Cell, typ PageType, b []byte, usable int) error {
+	if !typ.IsLeaf() {
+		if len(b) < 4 {
+			return ErrCorrupt
+		}
+		c.LeftChild = binary.BigEndian.Uint32(b)
+		b = b[4:]
+	}
+
+	if typ == INTERIOR_TABLE {
+		rowid, n := Varint(b)
+		if n == 0 {
+			return ErrCorrupt
+		}
+		c.RowID = int64(rowid)
+		return nil
+	}
+
+	size, n := Varint(b)
+	if n == 0 || size > math.MaxInt32 {
+		return ErrCorrupt
+	}
+	b = b[n:]
+	c.PayloadSize = int64(size)
+
+	if typ == LEAF_TABLE {
+		rowid, n := Varint(b)
+		if n == 0 {
+			return ErrCorrupt
+		}
+		b = b[n:]
+		c.RowID = int64(rowid)
+	}
+
+	local := localPayload(typ, c.PayloadSize, usable)
+	if local > int64(len(b)) {
+		return ErrCorrupt
+	}
+	c.Payload = b[:local]
+	if local < c.PayloadSize {
+		if local+4 > int64(len(b)) {
+			return ErrCorrupt
+		}
+		c.Overflow = binary.BigEndian.Uint32(b[local:])
+	}
+	return nil
+}
+
+// localPayload computes how much of the payload is stored in the page.
+//
+// https://sqlite.org/fileformat.html#cell_payload_overflow_pages
+func localPayload(typ PageType, size int64, usable int) int64 {
+	u := int64(usable)
+	x := u - 35
+	if !typ.IsTable() {
+		x = (u-12)*64/255 - 23
+	}
+	if size <= x {
+		return size
+	}
+	m := (u-12)*32/255 - 23
+	if k := m + (size-m)%(u-4); k <= x {
+		return k
+	}
+	return m
+}
+
+// ReadPayload reads the entire payload of c,
+// following the chain of overflow pages.
+func (c *Cell) ReadPayload(r io.ReaderAt, h *Header) ([]byte, error) {
+	res := make([]byte, 0, c.PayloadSize)
+	res = append(res, c.Payload...)
+
+	usable := h.UsableSize()
+	for next := c.Overflow; int64(len(res)) < c.PayloadSize; {
+		if next == 0 {
+			return nil, ErrCorrupt
+		}
+		page, err := ReadPage(r, h.PageSize, next)
+		if err != nil {
+			return nil, err
+		}
+		n := min(c.PayloadSize-int64(len(res)), int64(usable-4))
+		res = append(res, page[4:4+n]...)
+		next = binary.BigEndian.Uint32(page)
+	}
+	return res, nil
+}
+
+// WalkBTree walks the B-tree rooted at page root, depth-first,
+// calling fn for each page.
+func WalkBTree(r io.ReaderAt, h *Header, root uint32, fn func(*BTreePage) error) error {
+	seen := map[uint32]bool{}
+
+	var walk func(pgno uint32, depth int) error
+	walk = func(pgno uint32, depth int) error {
+		if seen[pgno] {
+			return ErrCorrupt
+		}
+		seen[pgno] = true
+
+		buf, err := ReadPage(r, h.PageSize, pgno)
+		if err != nil {
+			return err
+		}
+		page, err := ParseBTreePage(buf, pgno, h.UsableSize())
+		if err != nil {
+			return err
+		}
+		page.Depth = depth
+		if err := fn(page); err != nil {
+			return err
+		}
+		if page.Type.IsLeaf() {
+			return nil
+		}
+		for _, c := range page.Cells {
+			if err := walk(c.LeftChild, depth+1); err != nil {
+				return err
+			}
+		}
+		return walk(page.RightChild, depth+1)
+	}
+
+	return walk(root, 0)
+}
+
+// Varint decodes a variable-length integer from b,
+// and returns the value and the number of bytes read.
+// If b is too short, it returns 0, 0.
+//
+// https://sqlite.org/fileformat.html#varint
+func Varint(b []byte) (uint64, int) {
+	var v uint64
+	for i := 0; i < 9; i++ {
+		if i >= len(b) {
+			return 0, 0
+		}
+		if i == 8 {
+			return v<<8 | uint64(b[i]), 9
+		}
+		v = v<<7 | uint64(b[i]&0x7f)
+		if b[i] < 0x80 {
+			return v, i + 1
+		}
+	}
+	panic("unreachable")
+}
+
+// ParseRecord decodes a record into a slice of values
+// of type nil, int64, float64, string or []byte.
+// Text is returned as stored, in the database text encoding.
+//
+// https://sqlite.org/fileformat.html#record_format
+func ParseRecord(b []byte) ([]any, error) {
+	size, n := Varint(b)
+	if n == 0 || size > uint64(len(b)) || size < uint64(n) {
+		return nil, ErrCorrupt
+	}
+	types := b[n:size]
+	body := b[size:]
+
+	var res []any
+	for len(types) > 0 {
+		typ, n := Varint(types)
+		if n == 0 {
+			return nil, ErrCorrupt
+		}
+		types = types[n:]
+
+		var l int
+		switch {
+		case typ == 0, typ == 8, typ == 9:
+		case typ <= 4:
+			l = int(typ)
+		case typ == 5:
+			l = 6
+		case typ <= 7:
+			l = 8
+		case typ >= 12:
+			l = int((typ - 12) / 2)
+		default:
+			return nil, ErrCorrupt
+		}
+		if l > len(body) {
+			return nil, ErrCorrupt
+		}
+		v := body[:l]
+		body = body[l:]
+
+		switch {
+		case typ == 0:
+			res = append(res, nil)
+		case typ == 8:
+			res = append(res, int64(0))
+		case typ == 9:
+			res = append(res, int64(1))
+		case typ == 7:
+			res = append(res, math.Float64frombits(binary.BigEndian.Uint64(v)))
+		case typ <= 6:
+			var i int64
+			if len(v) > 0 && v[0] >= 0x80 {
+				i = -1
+			}
+			for _, b := range v {
+				i = i<<8 | int64(b)
+			}
+			res = append(res, i)
+		case typ%2 == 0:
+			res = append(res, append([]byte(nil), v...))
+		default:
+			res = append(res, string(v))
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,248 @@
+package fileformat_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ncruces/go-sqlite3"
+	_ "github.com/ncruces/go-sqlite3/embed"
+	"github.com/ncruces/go-sqlite3/util/fileformat"
+)
+
+func TestReadHeader(t *testing.T) {
+	t.Parallel()
+
+	f, err := os.Open("../../tests/testdata/wal.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	h, err := fileformat.ReadHeader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.PageSize != 512 {
+		t.Errorf("got %d, want 512", h.PageSize)
+	}
+	if h.WriteVersion != 2 || h.ReadVersion != 2 {
+		t.Errorf("got %d/%d, want 2/2", h.WriteVersion, h.ReadVersion)
+	}
+	if h.PageCount != 1 {
+		t.Errorf("got %d, want 1", h.PageCount)
+	}
+
+	var pages []*fileformat.BTreePage
+	err = fileformat.WalkBTree(f, h, 1, func(p *fileformat.BTreePage) error {
+		pages = append(pages, p)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pages) != 1 || pages[0].Type != fileformat.LEAF_TABLE || len(pages[0].Cells) != 0 {
+		t.Errorf("got %+v, want one empty leaf", pages)
+	}
+
+	_, err = fileformat.ParseHeader(make([]byte, fileformat.HeaderSize))
+	if !errors.Is(err, fileformat.ErrHeader) {
+		t.Errorf("got %v, want ErrHeader", err)
+	}
+}
+
+func TestWALReader(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "test.db")
+	db, err := sqlite3.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	err = db.Exec(`
+		PRAGMA page_size=1024;
+		PRAGMA journal_mode=WAL;
+		PRAGMA wal_autocheckpoint=0;
+		CREATE TABLE t (x);
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 50; i++ {
+		err = db.Exec(`INSERT INTO t VALUES (printf('%.*c', 3000, 'x'))`)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	wal, err := os.ReadFile(path + "-wal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := fileformat.NewWALReader(bytes.NewReader(wal))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Header().PageSize != 1024 {
+		t.Errorf("got %d, want 1024", r.Header().PageSize)
+	}
+
+	// Apply committed frames to an image of the database.
+	var image, pending pagesImage
+	var commits int
+	for {
+		f, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		pending.set(f.PageNumber, f.Data)
+		if f.IsCommit() {
+			commits++
+			image.merge(&pending, f.Commit)
+		}
+	}
+	if commits != 51 {
+		t.Errorf("got %d commits, want 51", commits)
+	}
+
+	// Corrupt the last frame.
+	wal[len(wal)-1] ^= 1
+	r, err = fileformat.NewWALReader(bytes.NewReader(wal))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for {
+		_, err = r.Next()
+		if err != nil {
+			break
+		}
+	}
+	if !errors.Is(err, fileformat.ErrChecksum) {
+		t.Errorf("got %v, want ErrChecksum", err)
+	}
+
+	db.Close()
+
+	rd := bytes.NewReader(image.bytes(1024))
+	h, err := fileformat.ReadHeader(rd)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Find the root page of t in sqlite_schema.
+	var root uint32
+	err = fileformat.WalkBTree(rd, h, 1, func(p *fileformat.BTreePage) error {
+		for _, c := range p.Cells {
+			if !p.Type.IsLeaf() {
+				continue
+			}
+			rec, err := fileformat.ParseRecord(c.Payload)
+			if err != nil {
+				return err
+			}
+			if rec[1] == "t" {
+				root = uint32(rec[3].(int64))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if root == 0 {
+		t.Fatal("table not found")
+	}
+
+	var rows int
+	var depth int
+	err = fileformat.WalkBTree(rd, h, root, func(p *fileformat.BTreePage) error {
+		depth = max(depth, p.Depth)
+		if !p.Type.IsLeaf() {
+			return nil
+		}
+		for _, c := range p.Cells {
+			rows++
+			payload, err := c.ReadPayload(rd, h)
+			if err != nil {
+				return err
+			}
+			rec, err := fileformat.ParseRecord(payload)
+			if err != nil {
+				return err
+			}
+			if s, ok := rec[0].(string); !ok || len(s) != 3000 {
+				t.Errorf("row %d: unexpected value", c.RowID)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rows != 50 {
+		t.Errorf("got %d rows, want 50", rows)
+	}
+	if depth == 0 {
+		t.Error("want interior pages")
+	}
+}
+
+func TestVarint(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		in  []byte
+		val uint64
+		n   int
+	}{
+		{[]byte{0x00}, 0, 1},
+		{[]byte{0x7f}, 127, 1},
+		{[]byte{0x81, 0x00}, 128, 2},
+		{[]byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, 1<<64 - 1, 9},
+		{[]byte{0x81}, 0, 0},
+	}
+	for _, tt := range tests {
+		val, n := fileformat.Varint(tt.in)
+		if val != tt.val || n != tt.n {
+			t.Errorf("Varint(%x) = %d, %d, want %d, %d", tt.in, val, n, tt.val, tt.n)
+		}
+	}
+}
+
+type pagesImage struct {
+	pages map[uint32][]byte
+	count uint32
+}
+
+func (m *pagesImage) set(pgno uint32, data []byte) {
+	if m.pages == nil {
+		m.pages = map[uint32][]byte{}
+	}
+	m.pages[pgno] = data
+}
+
+func (m *pagesImage) merge(o *pagesImage, count uint32) {
+	for pgno, data := range o.pages {
+		m.set(pgno, data)
+	}
+	clear(o.pages)
+	m.count = count
+}
+
+func (m *pagesImage) bytes(pageSize int) []byte {
+	buf := make([]byte, int(m.count)*pageSize)
+	for pgno, data := range m.pages {
+		if pgno <= m.count {
+			copy(buf[int(pgno-1)*pageSize:], data)
+		}
+	}
+	return buf
+}
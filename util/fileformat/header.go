@@ -0,0 +1,155 @@
+// Package fileformat reads SQLite database and WAL files.
+//
+// https://sqlite.org/fileformat.html
+package fileformat
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+var (
+	// ErrHeader is returned for invalid database or WAL headers.
+	ErrHeader = errors.New("fileformat: invalid header")
+	// ErrChecksum is returned for WAL headers and frames with invalid checksums.
+	ErrChecksum = errors.New("fileformat: invalid checksum")
+	// ErrSalt is returned for WAL frames with salts that don't match the header.
+	ErrSalt = errors.New("fileformat: invalid salt")
+	// ErrCorrupt is returned for malformed database pages.
+	ErrCorrupt = errors.New("fileformat: database corrupt")
+)
+
+// HeaderSize is the size of the database header.
+const HeaderSize = 100
+
+// Header is the database header.
+//
+// https://sqlite.org/fileformat.html#the_database_header
+type Header struct {
+	PageSize          int
+	WriteVersion      uint8 // 1 for legacy, 2 for WAL
+	ReadVersion       uint8 // 1 for legacy, 2 for WAL
+	ReservedBytes     uint8
+	ChangeCounter     uint32
+	PageCount         uint32
+	FreelistTrunk     uint32
+	FreelistCount     uint32
+	SchemaCookie      uint32
+	SchemaFormat      uint32
+	DefaultCacheSize  uint32
+	LargestRootPage   uint32 // non-zero for auto-vacuum databases
+	TextEncoding      uint32 // 1 for UTF-8, 2 for UTF-16le, 3 for UTF-16be
+	UserVersion       uint32
+	IncrementalVacuum uint32
+	ApplicationID     uint32
+	VersionValidFor   uint32
+	SQLiteVersion     uint32
+}
+
+// UsableSize returns the usable size of each page.
+func (h *Header) UsableSize() int {
+	return h.PageSize - int(h.ReservedBytes)
+}
+
+// ValidPageCount reports whether PageCount is valid.
+func (h *Header) ValidPageCount() bool {
+	return h.PageCount != 0 && h.ChangeCounter == h.VersionValidFor
+}
+
+// ReadHeader reads the database header from r.
+func ReadHeader(r io.ReaderAt) (*Header, error) {
+	var buf [HeaderSize]byte
+	if _, err := r.ReadAt(buf[:], 0); err != nil {
+		if err == io.EOF {
+			return nil, ErrHeader
+		}
+		return nil, err
+	}
+	return ParseHeader(buf[:])
+}
+
+// ParseHeader parses a database header.
+func ParseHeader(b []byte) (*Header, error) {
+	if len(b) < HeaderSize || string(b[:16]) != "SQLite format 3\x00" {
+		return nil, ErrHeader
+	}
+
+	h := Header{
+		PageSize:          int(binary.BigEndian.Uint16(b[16:])),
+		WriteVersion:      b[18],
+		ReadVersion:       b[19],
+		ReservedBytes:     b[20],
+		ChangeCounter:     binary.BigEndian.Uint32(b[24:]),
+		PageCount:         binary.BigEndian.Uint32(b[28:]),
+		FreelistTrunk:     binary.BigEndian.Uint32(b[32:]),
+		FreelistCount:     binary.BigEndian.Uint32(b[36:]),
+		SchemaCookie:      binary.BigEndian.Uint32(b[40:]),
+		SchemaFormat:      binary.BigEndian.Uint32(b[44:]),
+		DefaultCacheSize:  binary.BigEndian.Uint32(b[48:]),
+		LargestRootPage:   binary.BigEndian.Uint32(b[52:]),
+		TextEncoding:      binary.BigEndian.Uint32(b[56:]),
+		UserVersion:       binary.BigEndian.Uint32(b[60:]),
+		IncrementalVacuum: binary.BigEndian.Uint32(b[64:]),
+		ApplicationID:     binary.BigEndian.Uint32(b[68:]),
+		VersionValidFor:   binary.BigEndian.Uint32(b[92:]),
+		SQLiteVersion:     binary.BigEndian.Uint32(b[96:]),
+	}
+	if h.PageSize == 1 {
+		h.PageSize = 65536
+	}
+	if h.PageSize < 512 || h.PageSize&(h.PageSize-1) != 0 ||
+		b[21] != 64 || b[22] != 32 || b[23] != 32 ||
+		h.UsableSize() < 480 {
+		return nil, ErrHeader
+	}
+	return &h, nil
+}
+
+// ReadPage reads page number pgno from r.
+func ReadPage(r io.ReaderAt, pageSize int, pgno uint32) ([]byte, error) {
+	if pgno == 0 {
+		return nil, ErrCorrupt
+	}
+	buf := make([]byte, pageSize)
+	if _, err := r.ReadAt(buf, int64(pgno-1)*int64(pageSize)); err != nil {
+		if err == io.EOF {
+			return nil, ErrCorrupt
+		}
+		return nil, err
+	}
+	return buf, nil
+}
+
+// Freelist returns the page numbers of the freelist
+// trunk and leaf pages.
+//
+// https://sqlite.org/fileformat.html#the_freelist
+func Freelist(r io.ReaderAt, h *Header) ([]uint32, error) {
+	var pages []uint32
+	seen := map[uint32]bool{}
+	for trunk := h.FreelistTrunk; trunk != 0; {
+		if seen[trunk] || uint32(len(pages)) > h.FreelistCount {
+			return nil, ErrCorrupt
+		}
+		seen[trunk] = true
+		pages = append(pages, trunk)
+
+		buf, err := ReadPage(r, h.PageSize, trunk)
+		if err != nil {
+			return nil, err
+		}
+		n := binary.BigEndian.Uint32(buf[4:])
+		if n > uint32(h.UsableSize()/4-2) {
+			return nil, ErrCorrupt
+		}
+		for i := uint32(0); i < n; i++ {
+			pages = append(pages, binary.BigEndian.Uint32(buf[8+4*i:]))
+		}
+		trunk = binary.BigEndian.Uint32(buf[0:])
+	}
+	if uint32(len(pages)) != h.FreelistCount {
+		return nil, ErrCorrupt
+	}
+	return pages, nil
+}
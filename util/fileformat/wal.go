@@ -0,0 +1,171 @@
+package fileformat
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+const (
+	// WALHeaderSize is the size of the WAL header.
+	WALHeaderSize = 32
+	// WALFrameHeaderSize is the size of each WAL frame header.
+	WALFrameHeaderSize = 24
+
+	walMagic = 0x377f0682
+)
+
+// WALHeader is the WAL header.
+//
+// https://sqlite.org/fileformat.html#wal_file_format
+type WALHeader struct {
+	Magic         uint32
+	Version       uint32
+	PageSize      int
+	CheckpointSeq uint32
+	Salt1         uint32
+	Salt2         uint32
+	Checksum1     uint32
+	Checksum2     uint32
+}
+
+// BigEndian reports whether checksums
+// use big-endian words.
+func (h *WALHeader) BigEndian() bool {
+	return h.Magic&1 != 0
+}
+
+// ParseWALHeader parses and validates a WAL header.
+func ParseWALHeader(b []byte) (*WALHeader, error) {
+	if len(b) < WALHeaderSize {
+		return nil, ErrHeader
+	}
+	h := WALHeader{
+		Magic:         binary.BigEndian.Uint32(b[0:]),
+		Version:       binary.BigEndian.Uint32(b[4:]),
+		PageSize:      int(binary.BigEndian.Uint32(b[8:])),
+		CheckpointSeq: binary.BigEndian.Uint32(b[12:]),
+		Salt1:         binary.BigEndian.Uint32(b[16:]),
+		Salt2:         binary.BigEndian.Uint32(b[20:]),
+		Checksum1:     binary.BigEndian.Uint32(b[24:]),
+		Checksum2:     binary.BigEndian.Uint32(b[28:]),
+	}
+	if h.Magic&^1 != walMagic || h.PageSize < 512 || h.PageSize > 65536 ||
+		h.PageSize&(h.PageSize-1) != 0 {
+		return nil, ErrHeader
+	}
+	s1, s2 := WALChecksum(h.BigEndian(), 0, 0, b[:24])
+	if s1 != h.Checksum1 || s2 != h.Checksum2 {
+		return nil, ErrChecksum
+	}
+	return &h, nil
+}
+
+// WALFrame is a WAL frame.
+//
+// https://sqlite.org/fileformat.html#wal_file_format
+type WALFrame struct {
+	PageNumber uint32
+	// Commit is the size of the database in pages
+	// for commit frames, zero otherwise.
+	Commit    uint32
+	Salt1     uint32
+	Salt2     uint32
+	Checksum1 uint32
+	Checksum2 uint32
+	Data      []byte
+}
+
+// IsCommit reports whether this is the last frame of a transaction.
+func (f *WALFrame) IsCommit() bool {
+	return f.Commit != 0
+}
+
+// WALReader reads frames from a WAL file.
+type WALReader struct {
+	r      io.Reader
+	header WALHeader
+	s1, s2 uint32
+	buf    []byte
+}
+
+// NewWALReader reads and validates the WAL header from r.
+func NewWALReader(r io.Reader) (*WALReader, error) {
+	var buf [WALHeaderSize]byte
+	if _, err := io.ReadFull(r, buf[:]); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, ErrHeader
+		}
+		return nil, err
+	}
+	h, err := ParseWALHeader(buf[:])
+	if err != nil {
+		return nil, err
+	}
+	return &WALReader{
+		r:      r,
+		header: *h,
+		s1:     h.Checksum1,
+		s2:     h.Checksum2,
+		buf:    make([]byte, WALFrameHeaderSize+h.PageSize),
+	}, nil
+}
+
+// Header returns the WAL header.
+func (w *WALReader) Header() *WALHeader {
+	return &w.header
+}
+
+// Next reads and validates the next frame.
+//
+// At the end of the file, Next returns [io.EOF].
+// If the frame has salts that don't match the header, Next returns [ErrSalt];
+// if the frame has an invalid checksum, Next returns [ErrChecksum].
+// In both cases, the frame is not part of the WAL, and neither are any that follow it.
+// Frames that follow the last commit frame are not committed.
+func (w *WALReader) Next() (*WALFrame, error) {
+	if _, err := io.ReadFull(w.r, w.buf); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			err = io.EOF
+		}
+		return nil, err
+	}
+
+	b := w.buf
+	f := WALFrame{
+		PageNumber: binary.BigEndian.Uint32(b[0:]),
+		Commit:     binary.BigEndian.Uint32(b[4:]),
+		Salt1:      binary.BigEndian.Uint32(b[8:]),
+		Salt2:      binary.BigEndian.Uint32(b[12:]),
+		Checksum1:  binary.BigEndian.Uint32(b[16:]),
+		Checksum2:  binary.BigEndian.Uint32(b[20:]),
+		Data:       append([]byte(nil), b[WALFrameHeaderSize:]...),
+	}
+	if f.Salt1 != w.header.Salt1 || f.Salt2 != w.header.Salt2 {
+		return nil, ErrSalt
+	}
+
+	s1, s2 := WALChecksum(w.header.BigEndian(), w.s1, w.s2, b[:8])
+	s1, s2 = WALChecksum(w.header.BigEndian(), s1, s2, b[WALFrameHeaderSize:])
+	if s1 != f.Checksum1 || s2 != f.Checksum2 {
+		return nil, ErrChecksum
+	}
+	w.s1, w.s2 = s1, s2
+	return &f, nil
+}
+
+// WALChecksum computes the WAL checksum of b,
+// starting from s1 and s2.
+// The length of b must be a multiple of 8.
+//
+// https://sqlite.org/fileformat.html#checksum_algorithm
+func WALChecksum(bigEndian bool, s1, s2 uint32, b []byte) (uint32, uint32) {
+	var order binary.ByteOrder = binary.LittleEndian
+	if bigEndian {
+		order = binary.BigEndian
+	}
+	for i := 0; i+8 <= len(b); i += 8 {
+		s1 += order.Uint32(b[i:]) + s2
+		s2 += order.Uint32(b[i+4:]) + s1
+	}
+	return s1, s2
+}
@@ -0,0 +1,534 @@
+// Package jsonb implements encoding and decoding of SQLite's JSONB format.
+//
+// JSONB is a binary representation of JSON used by SQLite 3.45.0 and later.
+// Values are converted to and from JSON text, using [encoding/json]
+// to marshal and unmarshal Go values.
+//
+// https://sqlite.org/jsonb.html
+package jsonb
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"strings"
+)
+
+type elemType byte
+
+const (
+	typeNull elemType = iota
+	typeTrue
+	typeFalse
+	typeInt
+	typeInt5
+	typeFloat
+	typeFloat5
+	typeText
+	typeTextJ
+	typeText5
+	typeTextRaw
+	typeArray
+	typeObject
+)
+
+// Same as SQLite's JSON_MAX_DEPTH.
+const maxDepth = 1000
+
+var errInvalid = errors.New("jsonb: invalid data")
+
+// Marshal returns the JSONB encoding of v.
+func Marshal(v any) ([]byte, error) {
+	text, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return FromJSON(text)
+}
+
+// Unmarshal parses the JSONB-encoded data
+// and stores the result in the value pointed to by v.
+func Unmarshal(data []byte, v any) error {
+	text, err := ToJSON(data)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(text, v)
+}
+
+// ToJSON converts JSONB-encoded data to JSON text.
+func ToJSON(data []byte) ([]byte, error) {
+	buf, rest, err := appendJSON(nil, data, 0)
+	if err == nil && len(rest) != 0 {
+		err = errInvalid
+	}
+	if err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+// FromJSON converts JSON text to JSONB-encoded data.
+func FromJSON(text []byte) ([]byte, error) {
+	e := encoder{text: text}
+	buf, err := e.value(nil, 0)
+	if err == nil {
+		e.skip()
+		if e.pos != len(e.text) {
+			err = errInvalid
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+func header(data []byte) (t elemType, payload, rest []byte, err error) {
+	if len(data) == 0 {
+		return 0, nil, nil, errInvalid
+	}
+
+	t = elemType(data[0] & 0xf)
+	size := uint64(data[0] >> 4)
+	n := 1
+	switch size {
+	case 12:
+		n += 1
+	case 13:
+		n += 2
+	case 14:
+		n += 4
+	case 15:
+		n += 8
+	}
+	if len(data) < n {
+		return 0, nil, nil, errInvalid
+	}
+	switch n {
+	case 2:
+		size = uint64(data[1])
+	case 3:
+		size = uint64(binary.BigEndian.Uint16(data[1:]))
+	case 5:
+		size = uint64(binary.BigEndian.Uint32(data[1:]))
+	case 9:
+		size = binary.BigEndian.Uint64(data[1:])
+	}
+	if size > uint64(len(data)-n) {
+		return 0, nil, nil, errInvalid
+	}
+	end := n + int(size)
+	return t, data[n:end], data[end:], nil
+}
+
+func appendJSON(buf, data []byte, depth int) ([]byte, []byte, error) {
+	if depth > maxDepth {
+		return nil, nil, errInvalid
+	}
+
+	t, p, rest, err := header(data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch t {
+	case typeNull, typeTrue, typeFalse:
+		if len(p) != 0 {
+			return nil, nil, errInvalid
+		}
+		buf = append(buf, [...]string{"null", "true", "false"}[t]...)
+
+	case typeInt, typeFloat:
+		if len(p) == 0 {
+			return nil, nil, errInvalid
+		}
+		buf = append(buf, p...)
+
+	case typeInt5:
+		var i big.Int
+		if _, ok := i.SetString(strings.TrimPrefix(string(p), "+"), 0); !ok {
+			return nil, nil, errInvalid
+		}
+		buf = i.Append(buf, 10)
+
+	case typeFloat5:
+		buf, err = appendFloat5(buf, p)
+
+	case typeText, typeTextJ:
+		buf = append(buf, '"')
+		buf = append(buf, p...)
+		buf = append(buf, '"')
+
+	case typeText5:
+		buf, err = appendText5(buf, p)
+
+	case typeTextRaw:
+		buf = append(buf, '"')
+		for _, c := range p {
+			buf = appendEscaped(buf, c)
+		}
+		buf = append(buf, '"')
+
+	case typeArray:
+		buf = append(buf, '[')
+		for first := true; len(p) > 0; first = false {
+			if !first {
+				buf = append(buf, ',')
+			}
+			buf, p, err = appendJSON(buf, p, depth+1)
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+		buf = append(buf, ']')
+
+	case typeObject:
+		buf = append(buf, '{')
+		for first := true; len(p) > 0; first = false {
+			if !first {
+				buf = append(buf, ',')
+			}
+			if t := elemType(p[0] & 0xf); t < typeText || t > typeTextRaw {
+				return nil, nil, errInvalid
+			}
+			buf, p, err = appendJSON(buf, p, depth+1)
+			if err != nil {
+				return nil, nil, err
+			}
+			buf = append(buf, ':')
+			buf, p, err = appendJSON(buf, p, depth+1)
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+		buf = append(buf, '}')
+
+	default:
+		return nil, nil, errInvalid
+	}
+
+	if err != nil {
+		return nil, nil, err
+	}
+	return buf, rest, nil
+}
+
+func appendFloat5(buf, p []byte) ([]byte, error) {
+	s := strings.TrimPrefix(string(p), "+")
+	neg := strings.HasPrefix(s, "-")
+	if neg {
+		s = s[1:]
+	}
+
+	switch s {
+	case "":
+		return nil, errInvalid
+	case "NaN":
+		return append(buf, "null"...), nil
+	}
+	if neg {
+		buf = append(buf, '-')
+	}
+	if s == "Infinity" {
+		// Same as SQLite's json function.
+		return append(buf, "9.0e999"...), nil
+	}
+
+	if s[0] == '.' {
+		buf = append(buf, '0')
+	}
+	for i := 0; i < len(s); i++ {
+		buf = append(buf, s[i])
+		if s[i] == '.' && (i+1 == len(s) || !isDigit(s[i+1])) {
+			buf = append(buf, '0')
+		}
+	}
+	return buf, nil
+}
+
+func appendText5(buf, p []byte) ([]byte, error) {
+	buf = append(buf, '"')
+	for i := 0; i < len(p); i++ {
+		c := p[i]
+		if c != '\\' {
+			buf = appendEscaped(buf, c)
+			continue
+		}
+
+		i++
+		if i == len(p) {
+			return nil, errInvalid
+		}
+		switch c := p[i]; c {
+		case 'x':
+			if i+2 >= len(p) {
+				return nil, errInvalid
+			}
+			buf = append(buf, `\u00`...)
+			buf = append(buf, p[i+1:i+3]...)
+			i += 2
+		case '\'':
+			buf = append(buf, '\'')
+		case 'v':
+			buf = append(buf, `\u000b`...)
+		case '0':
+			buf = append(buf, `\u0000`...)
+		case '\n':
+			// Line continuation.
+		case '\r':
+			// Line continuation.
+			if i+1 < len(p) && p[i+1] == '\n' {
+				i++
+			}
+		case 0xe2:
+			// Line continuation, U+2028 or U+2029.
+			if i+2 >= len(p) || p[i+1] != 0x80 || p[i+2]|1 != 0xa9 {
+				return nil, errInvalid
+			}
+			i += 2
+		default:
+			buf = append(buf, '\\', c)
+		}
+	}
+	return append(buf, '"'), nil
+}
+
+func appendEscaped(buf []byte, c byte) []byte {
+	const hex = "0123456789abcdef"
+	switch {
+	case c == '"' || c == '\\':
+		return append(buf, '\\', c)
+	case c < 0x20:
+		return append(buf, '\\', 'u', '0', '0', hex[c>>4], hex[c&0xf])
+	}
+	return append(buf, c)
+}
+
+func appendHeader(buf []byte, t elemType, n int) []byte {
+	switch size := uint64(n); {
+	case size <= 11:
+		return append(buf, byte(size<<4)|byte(t))
+	case size <= 0xff:
+		return append(buf, 12<<4|byte(t), byte(size))
+	case size <= 0xffff:
+		return binary.BigEndian.AppendUint16(append(buf, 13<<4|byte(t)), uint16(size))
+	case size <= 0xffffffff:
+		return binary.BigEndian.AppendUint32(append(buf, 14<<4|byte(t)), uint32(size))
+	default:
+		return binary.BigEndian.AppendUint64(append(buf, 15<<4|byte(t)), size)
+	}
+}
+
+type encoder struct {
+	text []byte
+	pos  int
+}
+
+func (e *encoder) skip() {
+	for e.pos < len(e.text) {
+		switch e.text[e.pos] {
+		case ' ', '\t', '\n', '\r':
+			e.pos++
+		default:
+			return
+		}
+	}
+}
+
+func (e *encoder) value(buf []byte, depth int) ([]byte, error) {
+	if depth > maxDepth {
+		return nil, errInvalid
+	}
+
+	e.skip()
+	if e.pos >= len(e.text) {
+		return nil, errInvalid
+	}
+
+	switch c := e.text[e.pos]; {
+	case c == 'n':
+		return e.literal(buf, "null", typeNull)
+	case c == 't':
+		return e.literal(buf, "true", typeTrue)
+	case c == 'f':
+		return e.literal(buf, "false", typeFalse)
+	case c == '"':
+		return e.string(buf)
+	case c == '-' || isDigit(c):
+		return e.number(buf)
+	case c == '[':
+		return e.array(buf, depth)
+	case c == '{':
+		return e.object(buf, depth)
+	}
+	return nil, errInvalid
+}
+
+func (e *encoder) literal(buf []byte, lit string, t elemType) ([]byte, error) {
+	if !strings.HasPrefix(string(e.text[e.pos:]), lit) {
+		return nil, errInvalid
+	}
+	e.pos += len(lit)
+	return appendHeader(buf, t, 0), nil
+}
+
+func (e *encoder) string(buf []byte) ([]byte, error) {
+	t := typeText
+	start := e.pos + 1
+	for i := start; i < len(e.text); i++ {
+		switch c := e.text[i]; {
+		case c == '"':
+			e.pos = i + 1
+			buf = appendHeader(buf, t, i-start)
+			return append(buf, e.text[start:i]...), nil
+		case c == '\\':
+			t = typeTextJ
+			i++
+		case c < 0x20:
+			return nil, errInvalid
+		}
+	}
+	return nil, errInvalid
+}
+
+func (e *encoder) number(buf []byte) ([]byte, error) {
+	t := typeInt
+	start := e.pos
+	text := e.text
+
+	i := start
+	if text[i] == '-' {
+		i++
+	}
+	switch {
+	case i < len(text) && text[i] == '0':
+		i++
+	case i < len(text) && isDigit(text[i]):
+		i = digits(text, i)
+	default:
+		return nil, errInvalid
+	}
+	if i < len(text) && text[i] == '.' {
+		t = typeFloat
+		if j := digits(text, i+1); j > i+1 {
+			i = j
+		} else {
+			return nil, errInvalid
+		}
+	}
+	if i < len(text) && (text[i] == 'e' || text[i] == 'E') {
+		t = typeFloat
+		i++
+		if i < len(text) && (text[i] == '+' || text[i] == '-') {
+			i++
+		}
+		if j := digits(text, i); j > i {
+			i = j
+		} else {
+			return nil, errInvalid
+		}
+	}
+
+	e.pos = i
+	buf = appendHeader(buf, t, i-start)
+	return append(buf, text[start:i]...), nil
+}
+
+func (e *encoder) array(buf []byte, depth int) ([]byte, error) {
+	start := len(buf)
+	e.pos++
+	e.skip()
+	if e.pos < len(e.text) && e.text[e.pos] == ']' {
+		e.pos++
+		return appendHeader(buf, typeArray, 0), nil
+	}
+
+	for {
+		var err error
+		buf, err = e.value(buf, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		e.skip()
+		if e.pos >= len(e.text) {
+			return nil, errInvalid
+		}
+		c := e.text[e.pos]
+		e.pos++
+		switch c {
+		case ',':
+			continue
+		case ']':
+			return wrap(buf, start, typeArray), nil
+		}
+		return nil, errInvalid
+	}
+}
+
+func (e *encoder) object(buf []byte, depth int) ([]byte, error) {
+	start := len(buf)
+	e.pos++
+	e.skip()
+	if e.pos < len(e.text) && e.text[e.pos] == '}' {
+		e.pos++
+		return appendHeader(buf, typeObject, 0), nil
+	}
+
+	for {
+		var err error
+		e.skip()
+		if e.pos >= len(e.text) || e.text[e.pos] != '"' {
+			return nil, errInvalid
+		}
+		buf, err = e.string(buf)
+		if err != nil {
+			return nil, err
+		}
+		e.skip()
+		if e.pos >= len(e.text) || e.text[e.pos] != ':' {
+			return nil, errInvalid
+		}
+		e.pos++
+		buf, err = e.value(buf, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		e.skip()
+		if e.pos >= len(e.text) {
+			return nil, errInvalid
+		}
+		c := e.text[e.pos]
+		e.pos++
+		switch c {
+		case ',':
+			continue
+		case '}':
+			return wrap(buf, start, typeObject), nil
+		}
+		return nil, errInvalid
+	}
+}
+
+// wrap prefixes the payload that starts at buf[start:] with a header.
+func wrap(buf []byte, start int, t elemType) []byte {
+	var hdr [9]byte
+	size := len(buf) - start
+	h := appendHeader(hdr[:0], t, size)
+	buf = append(buf, h...)
+	copy(buf[start+len(h):], buf[start:start+size])
+	copy(buf[start:], h)
+	return buf
+}
+
+func digits(text []byte, i int) int {
+	for i < len(text) && isDigit(text[i]) {
+		i++
+	}
+	return i
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
@@ -0,0 +1,136 @@
+package jsonb_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ncruces/go-sqlite3"
+	_ "github.com/ncruces/go-sqlite3/embed"
+	"github.com/ncruces/go-sqlite3/util/jsonb"
+)
+
+var tests = []string{
+	`null`, `true`, `false`,
+	`0`, `-1`, `12345678901234567890`,
+	`0.5`, `-1e10`, `1.5E-3`,
+	`""`, `"abc"`, `"a\"b\\cé\n"`,
+	`[]`, `{}`, `[1,[2,[3,{}]]]`,
+	`{"a":1,"b":[true,false,null],"c":{"d":"e"}}`,
+	` { "a" : [ 1 , 2 ] } `,
+}
+
+func TestFromJSON(t *testing.T) {
+	db, err := sqlite3.Open(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	stmt, _, err := db.Prepare(`SELECT json(?) = json(?)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stmt.Close()
+
+	for _, tt := range tests {
+		blob, err := jsonb.FromJSON([]byte(tt))
+		if err != nil {
+			t.Fatalf("%s: %v", tt, err)
+		}
+
+		stmt.BindBlob(1, blob)
+		stmt.BindText(2, tt)
+		if stmt.Step() && !stmt.ColumnBool(0) {
+			t.Errorf("%s: not equal", tt)
+		}
+		if err := stmt.Reset(); err != nil {
+			t.Fatalf("%s: %v", tt, err)
+		}
+	}
+
+	for _, tt := range []string{``, `nul`, `[1,]`, `{"a"}`, `01`, `1.`, `"a`, `[] []`} {
+		_, err := jsonb.FromJSON([]byte(tt))
+		if err == nil {
+			t.Errorf("%q: want error", tt)
+		}
+	}
+}
+
+func TestToJSON(t *testing.T) {
+	db, err := sqlite3.Open(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	stmt, _, err := db.Prepare(`SELECT jsonb(?), json(?)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stmt.Close()
+
+	json5 := []string{
+		`0x1F`, `-0xff`, `+1`, `.5`, `5.`, `-.5e1`,
+		`Infinity`, `-Infinity`, `NaN`,
+		`'a"b'`, `'a\'b\x41\0'`, `"a\
+b"`,
+		`{a:1,'b':[2,],}`,
+	}
+
+	for _, tt := range append(tests, json5...) {
+		stmt.BindText(1, tt)
+		stmt.BindText(2, tt)
+		if !stmt.Step() {
+			t.Fatalf("%s: %v", tt, stmt.Err())
+		}
+
+		text, err := jsonb.ToJSON(stmt.ColumnRawBlob(0))
+		if err != nil {
+			t.Errorf("%s: %v", tt, err)
+		} else if got, want := string(text), stmt.ColumnText(1); got != want {
+			t.Errorf("%s: got %s, want %s", tt, got, want)
+		}
+		if err := stmt.Reset(); err != nil {
+			t.Fatalf("%s: %v", tt, err)
+		}
+	}
+
+	for _, tt := range []string{``, "\x00\x00", "\x13", "\x0d", "\x2b\x00", "\x1c\x01\x00"} {
+		_, err := jsonb.ToJSON([]byte(tt))
+		if err == nil {
+			t.Errorf("%q: want error", tt)
+		}
+	}
+}
+
+func TestMarshal(t *testing.T) {
+	type value struct {
+		Name  string
+		Tags  []string
+		Score float64
+		Valid bool
+		Extra map[string]any
+	}
+
+	want := value{
+		Name:  "name",
+		Tags:  []string{"a", "b"},
+		Score: 1.5,
+		Valid: true,
+		Extra: map[string]any{"x": "y"},
+	}
+
+	data, err := jsonb.Marshal(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got value
+	err = jsonb.Unmarshal(data, &got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
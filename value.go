@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/ncruces/go-sqlite3/internal/util"
+	"github.com/ncruces/go-sqlite3/util/jsonb"
 )
 
 // Value is any value that can be stored in a database table.
@@ -66,6 +67,16 @@ func (v Value) NumericType() Datatype {
 	return Datatype(r)
 }
 
+// Subtype returns the subtype of the value.
+// The JSON SQL functions set a subtype of 'J' on their results.
+// Subtype panics if the SQLite binary doesn't export sqlite3_value_subtype.
+//
+// https://sqlite.org/c3ref/value_subtype.html
+func (v Value) Subtype() uint {
+	r := v.c.call("sqlite3_value_subtype", v.protected())
+	return uint(r)
+}
+
 // Bool returns the value as a bool.
 // SQLite does not have a separate boolean storage class.
 // Instead, boolean values are retrieved as numbers,
@@ -192,6 +203,18 @@ func (v Value) JSON(ptr any) error {
 	return json.Unmarshal(data, ptr)
 }
 
+// JSONB parses a value, which must be a [JSONB] blob,
+// and stores the result in the value pointed to by ptr.
+// Values other than BLOBs are parsed as with [Value.JSON].
+//
+// [JSONB]: https://sqlite.org/jsonb.html
+func (v Value) JSONB(ptr any) error {
+	if v.Type() == BLOB {
+		return jsonb.Unmarshal(v.RawBlob(), ptr)
+	}
+	return v.JSON(ptr)
+}
+
 // NoChange returns true if and only if the value is unchanged
 // in a virtual table update operatiom.
 //